@@ -5,24 +5,51 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api/openapi"
 	"github.com/rainbowmga/timetravel/api/v1"
 	"github.com/rainbowmga/timetravel/api/v2"
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/limit"
 	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
 	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/webhook"
 )
 
 type API struct {
-	records service.RecordService
+	records  service.RecordService
+	changes  *service.ChangeFeed
+	webhooks *webhook.Store
+	keys     *apikey.Store
+	signer   *attest.Signer
+
+	// limits wraps the routes of every version, see SetLimits.
+	limits map[string]func(http.Handler) http.Handler
+}
+
+// NewAPI serves the records, streaming the versions published to changes.
+// webhooks and keys may be nil when the storage doesn't support them, and
+// signer when no attestation key is configured.
+func NewAPI(records service.RecordService, changes *service.ChangeFeed, webhooks *webhook.Store, keys *apikey.Store, signer *attest.Signer) *API {
+	return &API{records, changes, webhooks, keys, signer, map[string]func(http.Handler) http.Handler{}}
 }
 
-func NewAPI(records service.RecordService) *API {
-	return &API{records}
+// SetLimits limits the requests to the routes of version, "v1" or "v2",
+// whose rates are measured with clock, see middleware.LimitMiddleware.
+func (a *API) SetLimits(version string, limits limit.Limits, clock clock.Clock) {
+	a.limits[version] = middleware.LimitMiddleware(version, limits, clock)
 }
 
 // generates all api routes
 func (a *API) CreateRoutes(routes *mux.Router) {
 	apiV1 := v1.NewV1API(a.records)
 	routerV1 := routes.PathPrefix("/v1").Subrouter()
+	if limit, ok := a.limits["v1"]; ok {
+		routerV1.Use(limit)
+	}
 
 	routerV1.Path("/health").HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -35,7 +62,48 @@ func (a *API) CreateRoutes(routes *mux.Router) {
 
 	apiV1.CreateRoutes(routerV1)
 
-	apiV2 := v2.NewV2API(a.records)
+	apiV2 := v2.NewV2API(a.records, a.changes, a.webhooks, a.keys, a.signer)
 	routerV2 := routes.PathPrefix("/v2").Subrouter()
+	if limit, ok := a.limits["v2"]; ok {
+		routerV2.Use(limit)
+	}
 	apiV2.CreateRoutes(routerV2)
+
+	routes.Path("/openapi.json").HandlerFunc(a.GetOpenAPI).Methods("GET")
+}
+
+// OpenAPI describes every route created by CreateRoutes.
+func (a *API) OpenAPI() *openapi.Document {
+	doc := openapi.NewDocument("timetravel", "2.0.0")
+
+	doc.AddRoutes("/api", []openapi.Route{
+		{
+			Method:      "GET",
+			Path:        "/v1/health",
+			OperationID: "health",
+			Summary:     "Check that the server is up",
+			Response: &openapi.Schema{
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"ok": {Type: "boolean"}},
+			},
+		},
+		{
+			Method:      "GET",
+			Path:        "/openapi.json",
+			OperationID: "openapi",
+			Summary:     "This OpenAPI document",
+			Response:    &openapi.Schema{Type: "object"},
+		},
+	})
+	doc.AddRoutes("/api/v1", v1.NewV1API(a.records).Routes())
+	doc.AddRoutes("/api/v2", v2.NewV2API(a.records, a.changes, a.webhooks, a.keys, a.signer).Routes())
+
+	return doc
+}
+
+// GET /openapi.json
+// GetOpenAPI serves the OpenAPI document of the api.
+func (a *API) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	err := response.WriteJSON(w, a.OpenAPI(), http.StatusOK)
+	logging.LogError(err)
 }
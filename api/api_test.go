@@ -0,0 +1,284 @@
+package api_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api"
+	"github.com/rainbowmga/timetravel/api/graph"
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/webhook"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// pacific matches the offsets of the README examples.
+var pacific = time.FixedZone("", -7*60*60)
+
+// step is either a request, or a move of the server clock to at.
+type step struct {
+	method      string
+	path        string
+	body        string
+	accept      string
+	contentType string
+	at          string
+}
+
+func TestV1Records(t *testing.T) {
+	runGolden(t, "v1_records", []step{
+		{method: "GET", path: "/api/v1/health"},
+		{method: "GET", path: "/api/v1/records/32"},
+		{method: "GET", path: "/api/v1/records/0"},
+		{method: "GET", path: "/api/v1/records/abc"},
+		{method: "POST", path: "/api/v1/records/1", body: `{"first_name":"world"}`},
+		{at: "2024-08-25T16:13:03-07:00"},
+		{method: "POST", path: "/api/v1/records/1", body: `{"first_name":"world 2","city":"ok"}`},
+		{at: "2024-08-25T16:13:04-07:00"},
+		{method: "POST", path: "/api/v1/records/1", body: `{"first_name":null}`},
+		{method: "GET", path: "/api/v1/records/1"},
+		{method: "POST", path: "/api/v1/records/1", body: `not json`},
+		{method: "POST", path: "/api/v1/records/2", body: `{"hello":"world"}`},
+	})
+}
+
+func TestV2Records(t *testing.T) {
+	runGolden(t, "v2_records", []step{
+		{method: "GET", path: "/api/v2/records/32"},
+		{method: "GET", path: "/api/v2/records/32/versions"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"first_name":"Steve","last_name":"Jobs"}`},
+		{at: "2024-08-25T16:13:21-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"first_name":"Steven","middle_name":"Paul"}`},
+		{at: "2024-08-25T16:14:01-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"dob":"1955-02-24T00:00:00-07:00"}`},
+		{at: "2024-08-25T16:19:18-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"middle_name":null}`},
+		{at: "2024-08-25T16:30:00-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"first_name":"Steven","last_name":"Jobs"}`},
+		{method: "GET", path: "/api/v2/records/30/versions"},
+		{method: "GET", path: "/api/v2/records/30"},
+		{method: "GET", path: "/api/v2/records/30?at=2024-08-25T16:25:00-07:00"},
+		{method: "GET", path: "/api/v2/records/30?at=2024-08-25T16:13:30-07:00"},
+		{method: "GET", path: "/api/v2/records/30?at=2024-08-25T16:13:01-07:00"},
+		{method: "GET", path: "/api/v2/records/30?at=yesterday"},
+		{method: "GET", path: "/api/v2/records/0/versions"},
+		{method: "GET", path: "/api/v2/records/30/verification"},
+		{method: "GET", path: "/api/v2/records/32/verification"},
+		{method: "GET", path: "/api/v2/records/30/attestation?at=2024-08-25T16:14:30-07:00"},
+		{method: "GET", path: "/api/v2/records/30/attestation?at=2024-08-25T16:13:01-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"first_name":5}`},
+		{method: "POST", path: "/api/v2/records/30", body: `{"dob":"yesterday"}`},
+		{method: "POST", path: "/api/v2/records/30", body: `["first_name"]`},
+		{method: "GET", path: "/api/v1/records/30"},
+	})
+}
+
+func TestV2JSONAPI(t *testing.T) {
+	const jsonAPI = "application/vnd.api+json"
+
+	runGolden(t, "v2_jsonapi", []step{
+		{method: "GET", path: "/api/v2/records/30", accept: jsonAPI},
+		{method: "POST", path: "/api/v2/records/30", accept: jsonAPI, contentType: jsonAPI,
+			body: `{"data":{"type":"records","attributes":{"first_name":"Steve","last_name":"Jobs"}}}`},
+		{at: "2024-08-25T16:13:21-07:00"},
+		{method: "POST", path: "/api/v2/records/30", accept: jsonAPI,
+			body: `{"first_name":"Steven","middle_name":"Paul"}`},
+		{method: "GET", path: "/api/v2/records/30?at=2024-08-25T16:13:02-07:00", accept: jsonAPI},
+		{method: "GET", path: "/api/v2/records/30/versions", accept: "application/json, " + jsonAPI},
+		{method: "GET", path: "/api/v2/records/30?at=yesterday", accept: jsonAPI},
+		{method: "POST", path: "/api/v2/records/30", accept: jsonAPI, contentType: jsonAPI,
+			body: `{"first_name":"Steven"}`},
+		{method: "GET", path: "/api/v2/records/30", accept: jsonAPI + "; ext=bulk"},
+		{method: "GET", path: "/api/v1/records/30", accept: jsonAPI},
+		{at: "2024-08-25T16:14:00-07:00"},
+		{method: "POST", path: "/api/v2/records/30", contentType: jsonAPI,
+			body: `{"data":{"type":"records","attributes":{"city":"Cupertino"}}}`},
+	})
+}
+
+func TestV2Erasures(t *testing.T) {
+	runGolden(t, "v2_erasures", []step{
+		{method: "POST", path: "/api/v2/records/30", body: `{"first_name":"Steve","email":"steve@apple.com"}`},
+		{at: "2024-08-25T16:13:21-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"dob":"1955-02-24T00:00:00-07:00"}`},
+		{at: "2024-08-25T16:20:00-07:00"},
+		{method: "POST", path: "/api/v2/records/30/erasures", body: `{}`},
+		{method: "POST", path: "/api/v2/records/32/erasures", body: `{"reason":"gdpr request"}`},
+		{method: "POST", path: "/api/v2/records/30/erasures", body: `{"reason":"gdpr request"}`},
+		{method: "GET", path: "/api/v2/records/30/versions"},
+		{method: "GET", path: "/api/v2/records/30", accept: "application/vnd.api+json"},
+		{at: "2024-08-25T16:21:00-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"city":"Palo Alto"}`},
+		{method: "POST", path: "/api/v2/records/30/erasures", body: `{"reason":"gdpr request"}`},
+		{method: "GET", path: "/api/v2/records/30/erasures"},
+		{method: "GET", path: "/api/v2/records/30/verification"},
+	})
+}
+
+func TestV2Corrections(t *testing.T) {
+	runGolden(t, "v2_corrections", []step{
+		{method: "POST", path: "/api/v2/records/30", body: `{"first_name":"Steev","last_name":"Jobs"}`},
+		{at: "2024-08-25T16:13:21-07:00"},
+		{method: "POST", path: "/api/v2/records/30", body: `{"city":"Cupertino"}`},
+		{at: "2024-08-25T16:20:00-07:00"},
+		{method: "POST", path: "/api/v2/records/30/corrections", body: `{"updated_at":"2024-08-25T16:13:01-07:00","reason":"typo","data":{"first_name":"Steve"}}`},
+		{method: "POST", path: "/api/v2/records/30/corrections", body: `{"updated_at":"2024-08-25T16:13:02-07:00","reason":"typo","data":{"first_name":5}}`},
+		{method: "POST", path: "/api/v2/records/30/corrections", body: `{"updated_at":"2024-08-25T16:13:02-07:00","reason":"typo","data":{"id":31}}`},
+		{method: "POST", path: "/api/v2/records/30/corrections", body: `{"updated_at":"2024-08-25T16:13:02-07:00","data":{"first_name":"Steve"}}`},
+		{method: "POST", path: "/api/v2/records/32/corrections", body: `{"updated_at":"2024-08-25T16:13:02-07:00","reason":"typo","data":{"first_name":"Steve"}}`},
+		{method: "POST", path: "/api/v2/records/30/corrections", body: `{"updated_at":"2024-08-25T16:13:02-07:00","reason":"typo","data":{"first_name":"Steve"}}`},
+		{method: "GET", path: "/api/v2/records/30/versions"},
+		{method: "GET", path: "/api/v2/records/30/verification"},
+		{method: "POST", path: "/api/v2/records/30/erasures", body: `{"reason":"gdpr request"}`},
+		{method: "POST", path: "/api/v2/records/30/corrections", body: `{"updated_at":"2024-08-25T16:13:02-07:00","reason":"typo","data":{"first_name":"Steve"}}`},
+		{method: "GET", path: "/api/v2/records/30/maintenance"},
+	})
+}
+
+func TestV2Webhooks(t *testing.T) {
+	runGolden(t, "v2_webhooks", []step{
+		{method: "POST", path: "/api/v2/webhooks", body: `{"url":"ftp://billing.internal","secret":"s3cret"}`},
+		{method: "POST", path: "/api/v2/webhooks", body: `{"url":"https://billing.internal/hooks","secret":"s3cret","fields":["id"]}`},
+		{method: "POST", path: "/api/v2/webhooks", body: `{"url":"https://billing.internal/hooks","secret":"s3cret","fields":[1]}`},
+		{method: "POST", path: "/api/v2/webhooks", body: `{"url":"https://billing.internal/hooks"}`},
+		{method: "POST", path: "/api/v2/webhooks", body: `{"url":"https://billing.internal/hooks","secret":"s3cret","fields":["street","city"]}`},
+		{method: "POST", path: "/api/v2/webhooks", body: `{"url":"https://audit.internal/hooks","secret":"s3cret"}`},
+		{method: "GET", path: "/api/v2/webhooks"},
+		{method: "GET", path: "/api/v2/webhooks/dead-letters"},
+		{method: "DELETE", path: "/api/v2/webhooks/2"},
+		{method: "DELETE", path: "/api/v2/webhooks/2"},
+		{method: "GET", path: "/api/v2/webhooks"},
+	})
+}
+
+// runGolden replays steps against a server backed by a new sqlite
+// database, and compares the transcript with testdata/<name>.golden.
+func runGolden(t *testing.T, name string, steps []step) {
+	handler, clock := newServer(t)
+
+	var transcript bytes.Buffer
+	for _, s := range steps {
+		if s.at != "" {
+			at, err := time.Parse(time.RFC3339, s.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock.Set(at)
+			fmt.Fprintf(&transcript, "# at %s\n\n", s.at)
+			continue
+		}
+
+		var body io.Reader
+		fmt.Fprintf(&transcript, "> %s %s\n", s.method, s.path)
+		if s.accept != "" {
+			fmt.Fprintf(&transcript, "> Accept: %s\n", s.accept)
+		}
+		if s.contentType != "" {
+			fmt.Fprintf(&transcript, "> Content-Type: %s\n", s.contentType)
+		}
+		if s.body != "" {
+			body = strings.NewReader(s.body)
+			fmt.Fprintf(&transcript, "%s\n", s.body)
+		}
+
+		req := httptest.NewRequest(s.method, s.path, body)
+		if s.accept != "" {
+			req.Header.Set("Accept", s.accept)
+		}
+		if s.contentType != "" {
+			req.Header.Set("Content-Type", s.contentType)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		fmt.Fprintf(&transcript, "\n< %d %s\n", w.Code, w.Header().Get("Content-Type"))
+		fmt.Fprintf(&transcript, "%s\n", w.Body.String())
+	}
+
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		err := os.WriteFile(golden, transcript.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v; run `go test ./api -update` to create it", err)
+	}
+	if transcript.String() != string(want) {
+		t.Errorf("transcript differs from %s:\n%s", golden, transcript.String())
+	}
+}
+
+// newServer wires the api the same way as server.go, on a temp sqlite
+// database whose clock starts when record 30 of the README was created.
+// Authentication is disabled.
+func newServer(t *testing.T) (http.Handler, *clock.Fake) {
+	handler, clock, _ := newAuthServer(t, nil)
+	return handler, clock
+}
+
+// newAuthServer is newServer, authenticating requests with the
+// authenticator made by authenticator from the api keys of the returned
+// store.
+func newAuthServer(t *testing.T, authenticator func(keys *apikey.Store) auth.Authenticator) (http.Handler, *clock.Fake, *apikey.Store) {
+	db, err := model.OpenDb(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migration.NewMigrator(db, migration.SQLite).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, pacific))
+	sqlite := service.NewSQLiteRecordService(db, clock)
+	sqlite.SetChainKey(chain.Key("the chain key of the api tests"))
+	webhooks := webhook.NewStore(db, clock)
+	sqlite.AddVersionHook(webhooks.Enqueue)
+	keys := apikey.NewStore(db, clock)
+	signer, err := attest.LoadSigner(filepath.Join("testdata", "attestation.pem"), clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := service.NewChangeFeed()
+	records := service.NewPublishingRecordService(&sqlite, changes)
+
+	router := mux.NewRouter()
+	apiRoute := router.PathPrefix("/api").Subrouter()
+	api.NewAPI(&records, changes, &webhooks, &keys, signer).CreateRoutes(apiRoute)
+
+	graphHandler, err := graph.NewHandler(&records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphRoute := middleware.RequireScope(auth.ScopeRead, auth.ScopeHistory)(graphHandler)
+	router.Path("/graphql").Handler(graphRoute).Methods("GET", "POST")
+
+	var authenticate auth.Authenticator
+	if authenticator != nil {
+		authenticate = authenticator(&keys)
+	}
+	return middleware.AccessLogMiddleware(middleware.AuthMiddleware(authenticate)(middleware.TenantMiddleware(router))), clock, &keys
+}
@@ -0,0 +1,317 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rainbowmga/timetravel/concern/auth"
+)
+
+// request sends a request with the api key, if any, and returns the
+// status and body of the response.
+func request(handler http.Handler, method string, path string, key string, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+func TestAPIKeys(t *testing.T) {
+	handler, _, keys := newAuthServer(t, func(keys *apikey.Store) auth.Authenticator {
+		return keys
+	})
+
+	_, admin, err := keys.Issue(context.Background(), "admin", []string{"admin"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := request(handler, "POST", "/api/v2/api-keys", admin, `{"name":"reader","scopes":["read"]}`)
+	if code != http.StatusCreated {
+		t.Fatalf("POST /api/v2/api-keys = %d %s", code, body)
+	}
+	var issued struct {
+		ID  uint   `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal([]byte(body), &issued); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		body   string
+		want   int
+	}{
+		{"public", "GET", "/api/v1/health", "", "", http.StatusOK},
+		{"no key", "GET", "/api/v2/records/30", "", "", http.StatusUnauthorized},
+		{"invalid key", "GET", "/api/v2/records/30", "tt_invalid", "", http.StatusUnauthorized},
+		{"admin writes", "POST", "/api/v2/records/30", admin, `{"first_name":"Steve"}`, http.StatusOK},
+		{"reader reads", "GET", "/api/v2/records/30", issued.Key, "", http.StatusOK},
+		{"reader reads v1", "GET", "/api/v1/records/30", issued.Key, "", http.StatusOK},
+		{"reader writes", "POST", "/api/v2/records/30", issued.Key, `{"first_name":"Steven"}`, http.StatusForbidden},
+		{"reader looks back", "GET", "/api/v2/records/30?at=2024-08-25T16:13:02-07:00", issued.Key, "", http.StatusForbidden},
+		{"reader lists versions", "GET", "/api/v2/records/30/versions", issued.Key, "", http.StatusForbidden},
+		{"reader issues keys", "POST", "/api/v2/api-keys", issued.Key, `{"name":"admin","scopes":["admin"]}`, http.StatusForbidden},
+		{"invalid scope", "POST", "/api/v2/api-keys", admin, `{"name":"writer","scopes":["delete"]}`, http.StatusBadRequest},
+		{"admin lists keys", "GET", "/api/v2/api-keys", admin, "", http.StatusOK},
+		{"revoke", "DELETE", "/api/v2/api-keys/2", admin, "", http.StatusOK},
+		{"revoked key", "GET", "/api/v2/records/30", issued.Key, "", http.StatusUnauthorized},
+		{"revoke unknown", "DELETE", "/api/v2/api-keys/9", admin, "", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		code, body := request(handler, test.method, test.path, test.key, test.body)
+		if code != test.want {
+			t.Errorf("%s: %s %s = %d %s, want %d", test.name, test.method, test.path, code, body, test.want)
+		}
+	}
+
+	code, body = request(handler, "GET", "/api/v2/api-keys", admin, "")
+	if strings.Contains(body, "hash") || strings.Contains(body, issued.Key) {
+		t.Errorf("GET /api/v2/api-keys = %d %s, leaks secrets", code, body)
+	}
+}
+
+// tokens authenticate the principals they map to.
+type tokens map[string]auth.Principal
+
+func (t tokens) Authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	principal, ok := t[token]
+	if !ok {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+func TestFieldRoles(t *testing.T) {
+	handler, clock, _ := newAuthServer(t, func(*apikey.Store) auth.Authenticator {
+		return auth.DefaultFieldRoles.Restrict(tokens{
+			"admin":   {Subject: "admin", Scopes: []string{"admin"}},
+			"support": {Subject: "sam", Scopes: []string{"read", "write", "history"}, Roles: []string{"support"}},
+		})
+	})
+
+	code, body := request(handler, "POST", "/api/v2/records/30", "admin",
+		`{"first_name":"Steve","dob":"1955-02-24T00:00:00-07:00","email":"steve@apple.com","city":"Cupertino"}`)
+	if code != http.StatusOK {
+		t.Fatalf("POST as admin = %d %s", code, body)
+	}
+
+	for _, path := range []string{"/api/v2/records/30", "/api/v2/records/30/versions", "/api/v1/records/30"} {
+		code, body = request(handler, "GET", path, "support", "")
+		if code != http.StatusOK || !strings.Contains(body, `"city":"Cupertino"`) ||
+			strings.Contains(body, "dob") || strings.Contains(body, "email") || strings.Contains(body, "phone") {
+			t.Errorf("GET %s as support = %d %s, want city without dob, email and phone", path, code, body)
+		}
+	}
+
+	code, body = request(handler, "GET", "/api/v2/records/30/attestation", "support", "")
+	if code != http.StatusOK || !strings.Contains(body, `"omitted":["email","dob","phone"]`) ||
+		strings.Contains(body, "steve@apple.com") {
+		t.Errorf("GET attestation as support = %d %s, want dob, email and phone omitted", code, body)
+	}
+
+	code, body = request(handler, "POST", "/api/v2/records/30", "support", `{"city":"Palo Alto","email":null,"dob":"1955-02-25T00:00:00-07:00"}`)
+	if code != http.StatusForbidden || !strings.Contains(body, "forbidden; can't write dob, email") {
+		t.Errorf("POST restricted fields as support = %d %s, want a 403 listing dob and email", code, body)
+	}
+
+	clock.Advance(time.Second)
+	code, body = request(handler, "POST", "/api/v2/records/30", "support", `{"city":"Palo Alto"}`)
+	if code != http.StatusOK || !strings.Contains(body, `"city":"Palo Alto"`) || strings.Contains(body, "dob") {
+		t.Errorf("POST city as support = %d %s", code, body)
+	}
+
+	query := `{"query":"{ record(id: 30) { city dob versions { edges { node { changes { field } } } } } }"}`
+	code, body = request(handler, "POST", "/graphql", "support", query)
+	if code != http.StatusOK || !strings.Contains(body, `"dob":null`) || strings.Contains(body, `"field":"email"`) {
+		t.Errorf("POST /graphql as support = %d %s, want dob and its changes hidden", code, body)
+	}
+}
+
+func TestJSONAPIHistory(t *testing.T) {
+	handler, clock, _ := newAuthServer(t, func(*apikey.Store) auth.Authenticator {
+		return tokens{
+			"admin":  {Subject: "admin", Scopes: []string{"admin"}},
+			"reader": {Subject: "rita", Scopes: []string{"read"}},
+		}
+	})
+
+	code, body := request(handler, "POST", "/api/v2/records/30", "admin", `{"first_name":"Steve"}`)
+	if code != http.StatusOK {
+		t.Fatalf("POST as admin = %d %s", code, body)
+	}
+	clock.Advance(time.Second)
+	code, body = request(handler, "POST", "/api/v2/records/30", "admin", `{"first_name":"Steven"}`)
+	if code != http.StatusOK {
+		t.Fatalf("POST as admin = %d %s", code, body)
+	}
+
+	get := func(key string) string {
+		req := httptest.NewRequest("GET", "/api/v2/records/30", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Accept", "application/vnd.api+json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	body = get("reader")
+	if !strings.Contains(body, `"first_name":"Steven"`) || strings.Contains(body, `"meta":{"version"`) ||
+		strings.Contains(body, "versions") || strings.Contains(body, "previous") {
+		t.Errorf("GET as reader = %s, want the record without its version and history links", body)
+	}
+	body = get("admin")
+	if !strings.Contains(body, `"version":2`) || !strings.Contains(body, `"previous":"/api/v2/records/30?at=`) {
+		t.Errorf("GET as admin = %s, want the version and history links", body)
+	}
+}
+
+func TestFieldRolesOfAPIKeys(t *testing.T) {
+	handler, _, keys := newAuthServer(t, func(keys *apikey.Store) auth.Authenticator {
+		return auth.DefaultFieldRoles.Restrict(keys)
+	})
+
+	_, admin, err := keys.Issue(context.Background(), "admin", []string{"admin"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := request(handler, "POST", "/api/v2/api-keys", admin, `{"name":"sam","scopes":["read","write"],"roles":["support"]}`)
+	if code != http.StatusCreated || !strings.Contains(body, `"roles":["support"]`) {
+		t.Fatalf("POST /api/v2/api-keys = %d %s", code, body)
+	}
+	var issued struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal([]byte(body), &issued); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body = request(handler, "POST", "/api/v2/records/30", admin,
+		`{"first_name":"Steve","dob":"1955-02-24T00:00:00-07:00","city":"Cupertino"}`)
+	if code != http.StatusOK {
+		t.Fatalf("POST as admin = %d %s", code, body)
+	}
+
+	code, body = request(handler, "GET", "/api/v2/records/30", issued.Key, "")
+	if code != http.StatusOK || !strings.Contains(body, `"city":"Cupertino"`) || strings.Contains(body, "dob") {
+		t.Errorf("GET as support key = %d %s, want city without dob", code, body)
+	}
+
+	code, body = request(handler, "POST", "/api/v2/records/30", issued.Key, `{"dob":null}`)
+	if code != http.StatusForbidden {
+		t.Errorf("POST dob as support key = %d %s, want 403", code, body)
+	}
+
+	code, body = request(handler, "POST", "/api/v2/api-keys", admin, `{"name":"x","scopes":["read"],"roles":["a,b"]}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("POST /api/v2/api-keys with an invalid role = %d %s, want 400", code, body)
+	}
+
+	// roles unknown to the field roles give access to no field
+	code, body = request(handler, "POST", "/api/v2/api-keys", admin, `{"name":"ivan","scopes":["read"],"roles":["intern"]}`)
+	if code != http.StatusCreated {
+		t.Fatalf("POST /api/v2/api-keys = %d %s", code, body)
+	}
+	if err := json.Unmarshal([]byte(body), &issued); err != nil {
+		t.Fatal(err)
+	}
+	code, body = request(handler, "GET", "/api/v2/records/30", issued.Key, "")
+	if code != http.StatusOK || strings.Contains(body, "first_name") || strings.Contains(body, "city") {
+		t.Errorf("GET as intern key = %d %s, want no field", code, body)
+	}
+
+	// admins restricted by roles only grant theirs
+	_, supportAdmin, err := keys.Issue(context.Background(), "sam-admin", []string{"admin"}, []string{"support"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, body = request(handler, "POST", "/api/v2/api-keys", supportAdmin, `{"name":"x","scopes":["read"],"roles":["intern","support"]}`)
+	if code != http.StatusForbidden || !strings.Contains(body, "forbidden; can't grant intern") {
+		t.Errorf("POST /api/v2/api-keys with another role as support admin = %d %s, want 403", code, body)
+	}
+	code, body = request(handler, "POST", "/api/v2/api-keys", supportAdmin, `{"name":"x","scopes":["read"]}`)
+	if code != http.StatusCreated || !strings.Contains(body, `"roles":["support"]`) {
+		t.Errorf("POST /api/v2/api-keys without roles as support admin = %d %s, want the support role", code, body)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	handler, _, keys := newAuthServer(t, func(keys *apikey.Store) auth.Authenticator {
+		return keys
+	})
+
+	_, admin, err := keys.Issue(context.Background(), "admin", []string{"admin"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, acme, err := keys.Issue(context.Background(), "acme", []string{"admin"}, nil, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, reader, err := keys.Issue(context.Background(), "reader", []string{"read"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(method string, path string, key string, tenant string, body string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		tenant string
+		body   string
+		want   int
+		// contains is expected in the body, when set
+		contains string
+	}{
+		{"bound key writes", "POST", "/api/v2/records/1", acme, "", `{"first_name":"Steve"}`, http.StatusOK, "Steve"},
+		{"header writes", "POST", "/api/v2/records/1", admin, "globex", `{"first_name":"Jane"}`, http.StatusOK, "Jane"},
+		{"bound key reads", "GET", "/api/v2/records/1", acme, "acme", "", http.StatusOK, "Steve"},
+		{"header reads", "GET", "/api/v2/records/1/versions", admin, "acme", "", http.StatusOK, "Steve"},
+		{"other tenant", "GET", "/api/v1/records/1", admin, "globex", "", http.StatusOK, "Jane"},
+		{"default tenant", "GET", "/api/v2/records/1", admin, "", "", http.StatusBadRequest, ""},
+		{"bound key names another tenant", "GET", "/api/v2/records/1", acme, "globex", "", http.StatusForbidden, "another tenant"},
+		{"invalid tenant", "GET", "/api/v2/records/1", admin, "../acme", "", http.StatusBadRequest, "invalid tenant"},
+		{"reader names a tenant", "GET", "/api/v2/records/1", reader, "acme", "", http.StatusForbidden, "requires the admin scope"},
+		{"reader names the default tenant", "GET", "/api/v2/records/1", reader, "default", "", http.StatusBadRequest, "does not exist"},
+		{"bound key issues keys for another tenant", "POST", "/api/v2/api-keys", acme, "", `{"name":"x","scopes":["read"],"tenant":"globex"}`, http.StatusForbidden, ""},
+		{"bound key issues keys", "POST", "/api/v2/api-keys", acme, "", `{"name":"reader","scopes":["read"]}`, http.StatusCreated, `"tenant":"acme"`},
+		{"bound key lists keys", "GET", "/api/v2/api-keys", acme, "", "", http.StatusOK, `"name":"reader"`},
+		{"bound key revokes another tenant's key", "DELETE", "/api/v2/api-keys/1", acme, "", "", http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		code, body := send(test.method, test.path, test.key, test.tenant, test.body)
+		if code != test.want || !strings.Contains(body, test.contains) {
+			t.Errorf("%s: %s %s = %d %s, want %d with %q", test.name, test.method, test.path, code, body, test.want, test.contains)
+		}
+	}
+
+	_, body := send("GET", "/api/v2/api-keys", acme, "", "")
+	if strings.Contains(body, `"name":"admin"`) {
+		t.Errorf("GET /api/v2/api-keys as acme = %s, lists keys of other tenants", body)
+	}
+}
@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// event is a server-sent event, without its type.
+type event struct {
+	id   string
+	data string
+}
+
+// stream opens the change stream, resuming after lastEventID if set.
+func stream(t *testing.T, ctx context.Context, url string, lastEventID string) *bufio.Scanner {
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"/api/v2/records/changes/stream?id=30", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("got content type %q", got)
+	}
+	return bufio.NewScanner(resp.Body)
+}
+
+func next(t *testing.T, events *bufio.Scanner) event {
+	var e event
+	for events.Scan() {
+		line := events.Text()
+		switch {
+		case line == "":
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	t.Fatalf("stream ended: %v", events.Err())
+	return e
+}
+
+func post(t *testing.T, url string, body string) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST %s: got status %d", url, resp.StatusCode)
+	}
+}
+
+func TestChangesStream(t *testing.T) {
+	handler, clock := newServer(t)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := stream(t, ctx, srv.URL, "")
+
+	post(t, srv.URL+"/api/v2/records/30", `{"first_name":"Steve"}`)
+	post(t, srv.URL+"/api/v2/records/31", `{"first_name":"Jane"}`)
+	clock.Advance(time.Minute)
+	post(t, srv.URL+"/api/v2/records/30", `{"first_name":"Steve"}`)
+	post(t, srv.URL+"/api/v2/records/30", `{"first_name":"Steven"}`)
+
+	first := next(t, events)
+	if first.id != "1" || !strings.Contains(first.data, `"first_name":"Steve"`) {
+		t.Errorf("got first event %+v", first)
+	}
+	second := next(t, events)
+	if second.id != "3" || !strings.Contains(second.data, `"first_name":"Steven"`) {
+		t.Errorf("got second event %+v", second)
+	}
+
+	// a dropped client catches up from its last event
+	cancel()
+	clock.Advance(time.Minute)
+	post(t, srv.URL+"/api/v2/records/30", `{"city":"Cupertino"}`)
+
+	events = stream(t, context.Background(), srv.URL, first.id)
+	for _, want := range []string{"3", "4"} {
+		if got := next(t, events); got.id != want {
+			t.Errorf("got resumed event %+v, want id %s", got, want)
+		}
+	}
+}
+
+func TestChangesStreamValidation(t *testing.T) {
+	handler, _ := newServer(t)
+
+	for _, tc := range []struct {
+		path        string
+		lastEventID string
+	}{
+		{"/api/v2/records/changes/stream?id=0", ""},
+		{"/api/v2/records/changes/stream", "abc"},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Last-Event-ID", tc.lastEventID)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s with Last-Event-ID %q: got status %d", tc.path, tc.lastEventID, w.Code)
+		}
+	}
+}
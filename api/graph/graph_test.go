@@ -0,0 +1,93 @@
+package graph_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/api/graph"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+func TestGraphQL(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+	clock := clock.NewFake(start)
+	records := service.NewMemoryRecordService(clock)
+
+	record, _ := records.CreateRecord(ctx, 30, map[string]interface{}{"first_name": "Steve", "middle_name": "Paul"})
+	clock.Advance(time.Minute)
+	record, _ = records.UpdateRecord(ctx, record, map[string]interface{}{"first_name": "Steven"})
+	clock.Advance(time.Minute)
+	_, err := records.UpdateRecord(ctx, record, map[string]interface{}{"middle_name": nil, "city": "Cupertino"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counted := &countedVersions{RecordService: &records}
+	handler, err := graph.NewHandler(counted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := `{
+		record(id: 30, at: "2024-08-25T16:14:30Z") {
+			first_name
+			version
+			versions(first: 2) {
+				totalCount
+				edges { cursor node { version } }
+				pageInfo { hasNextPage endCursor }
+			}
+		}
+		older: versions(id: 30, after: "dmVyc2lvbjoy") {
+			edges { node { version first_name changes { field type before after } } }
+		}
+		diff(id: 30, from: "2024-08-25T16:13:02Z", to: "2024-08-25T16:15:02Z") {
+			field type before after
+		}
+		missing: record(id: 31) { id }
+	}`
+	body, _ := json.Marshal(map[string]string{"query": query})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body))))
+
+	want := `{"data":{` +
+		`"diff":[` +
+		`{"after":"Steven","before":"Steve","field":"first_name","type":"update"},` +
+		`{"after":null,"before":"Paul","field":"middle_name","type":"delete"},` +
+		`{"after":"Cupertino","before":null,"field":"city","type":"create"}],` +
+		`"missing":null,` +
+		`"older":{"edges":[{"node":{"changes":[` +
+		`{"after":"Steve","before":null,"field":"first_name","type":"create"},` +
+		`{"after":"Paul","before":null,"field":"middle_name","type":"create"}],` +
+		`"first_name":"Steve","version":1}}]},` +
+		`"record":{"first_name":"Steven","version":2,"versions":{` +
+		`"edges":[{"cursor":"dmVyc2lvbjoz","node":{"version":3}},{"cursor":"dmVyc2lvbjoy","node":{"version":2}}],` +
+		`"pageInfo":{"endCursor":"dmVyc2lvbjoy","hasNextPage":true},"totalCount":3}}}}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("response:\n%s\nwant:\n%s", w.Body.String(), want)
+	}
+
+	// every node of record 30 shares the versions read for the request
+	if counted.calls != 1 {
+		t.Errorf("GetVersions called %d times, want once", counted.calls)
+	}
+}
+
+// countedVersions counts the calls to GetVersions.
+type countedVersions struct {
+	service.RecordService
+	calls int
+}
+
+func (c *countedVersions) GetVersions(ctx context.Context, id uint) ([]model.Record, error) {
+	c.calls++
+	return c.RecordService.GetVersions(ctx, id)
+}
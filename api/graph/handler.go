@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+type Handler struct {
+	schema graphql.Schema
+}
+
+func NewHandler(records service.RecordService) (*Handler, error) {
+	schema, err := NewSchema(records)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{schema}, nil
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GET /graphql?query=...
+// POST /graphql {"query": ..., "operationName": ..., "variables": ...}
+// ServeHTTP executes a GraphQL query.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeInvalid(w, "invalid variables; could not parse json")
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeInvalid(w, "invalid input; could not parse json")
+			return
+		}
+	default:
+		writeInvalid(w, "method not allowed; use GET or POST")
+		return
+	}
+
+	if req.Query == "" {
+		writeInvalid(w, "invalid input; query is required")
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        withHistory(r.Context()),
+	})
+
+	err := response.WriteJSON(w, result, http.StatusOK)
+	logging.LogError(err)
+}
+
+func writeInvalid(w http.ResponseWriter, message string) {
+	err := response.WriteError(w, message, http.StatusBadRequest)
+	logging.LogError(err)
+}
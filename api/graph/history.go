@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// history caches the versions of the records read by a request, so that
+// the version number and changes of every node of a query don't read
+// them again.
+type history struct {
+	mu      sync.Mutex
+	records map[uint]*versions
+}
+
+// versions are the versions of a record, newest first, with the index
+// of each by the time it was updated.
+type versions struct {
+	list  []model.Record
+	index map[int64]int
+	err   error
+}
+
+type historyKey struct{}
+
+// withHistory starts the cache of the versions read by a request.
+func withHistory(ctx context.Context) context.Context {
+	return context.WithValue(ctx, historyKey{}, &history{records: map[uint]*versions{}})
+}
+
+// loadVersions returns the versions of the record id, read with load
+// once per request. Outside of requests, they are read every time.
+func loadVersions(ctx context.Context, id uint, load func() ([]model.Record, error)) *versions {
+	h, ok := ctx.Value(historyKey{}).(*history)
+	if !ok {
+		return newVersions(load())
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cached, ok := h.records[id]; ok {
+		return cached
+	}
+	loaded := newVersions(load())
+	h.records[id] = loaded
+	return loaded
+}
+
+func newVersions(list []model.Record, err error) *versions {
+	index := make(map[int64]int, len(list))
+	for i, version := range list {
+		index[version.UpdatedAt.UnixNano()] = i
+	}
+	return &versions{list, index, err}
+}
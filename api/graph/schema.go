@@ -0,0 +1,455 @@
+// Package graph serves the records over GraphQL at /graphql, resolving
+// through service.RecordService:
+//
+//	{
+//	  record(id: 30, at: "2024-08-25T16:25:00-07:00") {
+//	    first_name
+//	    versions(first: 5) {
+//	      edges { node { version updated_at changes { field type before after } } }
+//	    }
+//	  }
+//	}
+package graph
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobeam/stringy"
+	"github.com/graphql-go/graphql"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+var errInvalidID = errors.New("invalid id; id must be a positive number")
+
+type resolver struct {
+	records service.RecordService
+}
+
+// NewSchema builds the GraphQL schema of the records.
+func NewSchema(records service.RecordService) (graphql.Schema, error) {
+	r := &resolver{records}
+
+	changeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Change",
+		Description: "A change of a field between two versions of a record",
+		Fields: graphql.Fields{
+			"field": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"type": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "create, update or delete",
+			},
+			"before": &graphql.Field{Type: graphql.String},
+			"after":  &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	var connectionType *graphql.Object
+
+	recordFields := graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return int(p.Source.(model.Record).ID), nil
+			},
+		},
+		"version": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Int),
+			Description: "Number of the version, starting at 1",
+			Resolve:     r.resolveVersionNumber,
+		},
+		"changes": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(changeType))),
+			Description: "Changes made by this version to the previous one",
+			Resolve:     r.resolveChanges,
+		},
+	}
+	addDataFields(recordFields)
+
+	recordType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Record",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			recordFields["versions"] = &graphql.Field{
+				Type:        graphql.NewNonNull(connectionType),
+				Description: "Versions of the record, newest first",
+				Args:        connectionArgs(),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.versions(p, p.Source.(model.Record).ID)
+				},
+			}
+			return recordFields
+		}),
+	})
+
+	connectionType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "VersionConnection",
+		Fields: graphql.Fields{
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"edges": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(
+					graphql.NewObject(graphql.ObjectConfig{
+						Name: "VersionEdge",
+						Fields: graphql.Fields{
+							"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+							"node":   &graphql.Field{Type: graphql.NewNonNull(recordType)},
+						},
+					}),
+				))),
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewObject(graphql.ObjectConfig{
+					Name: "PageInfo",
+					Fields: graphql.Fields{
+						"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+						"endCursor":   &graphql.Field{Type: graphql.String},
+					},
+				})),
+			},
+		},
+	})
+
+	idArg := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"record": &graphql.Field{
+				Type:        recordType,
+				Description: "The latest version of a record, or its version at a given time",
+				Args: graphql.FieldConfigArgument{
+					"id": idArg,
+					"at": &graphql.ArgumentConfig{Type: graphql.DateTime},
+				},
+				Resolve: r.resolveRecord,
+			},
+			"versions": &graphql.Field{
+				Type:        connectionType,
+				Description: "Versions of a record, newest first",
+				Args: connectionArgs(graphql.FieldConfigArgument{
+					"id": idArg,
+				}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := idFrom(p)
+					if err != nil {
+						return nil, err
+					}
+					return r.versions(p, id)
+				},
+			},
+			"diff": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(changeType))),
+				Description: "Changes of a record between its versions at two times",
+				Args: graphql.FieldConfigArgument{
+					"id":   idArg,
+					"from": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.DateTime)},
+					"to":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.DateTime)},
+				},
+				Resolve: r.resolveDiff,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// addDataFields adds the fields of RecordJSON's data, derived from
+// model.Record the same way as ToJSON.
+func addDataFields(fields graphql.Fields) {
+	mutable := map[string]bool{}
+	for _, field := range (model.Record{}).MutableFields() {
+		mutable[field] = true
+	}
+
+	t := reflect.TypeOf(model.Record{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !model.IsDataField(field.Name) {
+			continue
+		}
+
+		index := i
+		fieldType := graphql.Output(graphql.String)
+		if field.Type == reflect.TypeOf(time.Time{}) {
+			fieldType = graphql.DateTime
+		}
+
+		fieldKey := stringy.New(field.Name).SnakeCase().ToLower()
+		// mutable fields are null for callers who can't read them, and
+		// once erased
+		if !mutable[fieldKey] {
+			fieldType = graphql.NewNonNull(fieldType)
+		}
+		fields[fieldKey] = &graphql.Field{
+			Type: fieldType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				record := p.Source.(model.Record)
+				if contains(auth.Unreadable(p.Context), fieldKey) || (mutable[fieldKey] && record.ErasedAt != nil) {
+					return nil, nil
+				}
+				return reflect.ValueOf(record).Field(index).Interface(), nil
+			},
+		}
+	}
+
+	fields["erased_at"] = &graphql.Field{
+		Type: graphql.DateTime,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(model.Record).ErasedAt, nil
+		},
+	}
+}
+
+func connectionArgs(args ...graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	connection := graphql.FieldConfigArgument{
+		"first": &graphql.ArgumentConfig{Type: graphql.Int},
+		"after": &graphql.ArgumentConfig{Type: graphql.String},
+	}
+	for _, arg := range args {
+		for name, config := range arg {
+			connection[name] = config
+		}
+	}
+	return connection
+}
+
+func idFrom(p graphql.ResolveParams) (uint, error) {
+	id, _ := p.Args["id"].(int)
+	if id <= 0 {
+		return 0, errInvalidID
+	}
+	return uint(id), nil
+}
+
+func (r *resolver) resolveRecord(p graphql.ResolveParams) (interface{}, error) {
+	id, err := idFrom(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var record model.Record
+	if at, ok := p.Args["at"].(time.Time); ok {
+		record, err = r.records.GetRecordAt(p.Context, id, at)
+	} else {
+		record, err = r.records.GetRecord(p.Context, id)
+	}
+	if errors.Is(err, service.ErrRecordDoesNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// getVersions returns the versions of the record id, newest first,
+// read once per request, see history.
+func (r *resolver) getVersions(p graphql.ResolveParams, id uint) *versions {
+	return loadVersions(p.Context, id, func() ([]model.Record, error) {
+		return r.records.GetVersions(p.Context, id)
+	})
+}
+
+// position returns the versions of record, newest first, and the index
+// of record among them.
+func (r *resolver) position(p graphql.ResolveParams, record model.Record) ([]model.Record, int, error) {
+	versions := r.getVersions(p, record.ID)
+	if versions.err != nil {
+		return nil, 0, versions.err
+	}
+
+	i, ok := versions.index[record.UpdatedAt.UnixNano()]
+	if !ok {
+		return nil, 0, fmt.Errorf("version of record %d not found", record.ID)
+	}
+	return versions.list, i, nil
+}
+
+func (r *resolver) resolveVersionNumber(p graphql.ResolveParams) (interface{}, error) {
+	versions, i, err := r.position(p, p.Source.(model.Record))
+	if err != nil {
+		return nil, err
+	}
+	return len(versions) - i, nil
+}
+
+func (r *resolver) resolveChanges(p graphql.ResolveParams) (interface{}, error) {
+	record := p.Source.(model.Record)
+	versions, i, err := r.position(p, record)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := model.Record{}
+	if i+1 < len(versions) {
+		previous = versions[i+1]
+	}
+	return diff(previous, record, auth.Unreadable(p.Context)), nil
+}
+
+func (r *resolver) resolveDiff(p graphql.ResolveParams) (interface{}, error) {
+	id, err := idFrom(p)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := r.records.GetRecordAt(p.Context, id, p.Args["from"].(time.Time))
+	if err != nil && !errors.Is(err, service.ErrRecordDoesNotExist) {
+		return nil, err
+	}
+
+	to, err := r.records.GetRecordAt(p.Context, id, p.Args["to"].(time.Time))
+	if err != nil && !errors.Is(err, service.ErrRecordDoesNotExist) {
+		return nil, err
+	}
+
+	return diff(from, to, auth.Unreadable(p.Context)), nil
+}
+
+type versionEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   model.Record `json:"node"`
+}
+
+func (r *resolver) versions(p graphql.ResolveParams, id uint) (interface{}, error) {
+	loaded := r.getVersions(p, id)
+	if errors.Is(loaded.err, service.ErrRecordDoesNotExist) {
+		return nil, nil
+	}
+	if loaded.err != nil {
+		return nil, loaded.err
+	}
+	versions := loaded.list
+
+	// versions are newest first, so `after` a version are the older ones
+	start := 0
+	if after, ok := p.Args["after"].(string); ok {
+		number, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		start = len(versions) - number + 1
+		if start < 0 {
+			start = 0
+		}
+	}
+
+	end := len(versions)
+	if first, ok := p.Args["first"].(int); ok {
+		if first < 0 {
+			return nil, errors.New("invalid first; first must not be negative")
+		}
+		if start+first < end {
+			end = start + first
+		}
+	}
+
+	edges := []versionEdge{}
+	for i := start; i < end; i++ {
+		edges = append(edges, versionEdge{encodeCursor(len(versions) - i), versions[i]})
+	}
+
+	var endCursor interface{}
+	if len(edges) > 0 {
+		endCursor = edges[len(edges)-1].Cursor
+	}
+
+	return map[string]interface{}{
+		"totalCount": len(versions),
+		"edges":      edges,
+		"pageInfo": map[string]interface{}{
+			"hasNextPage": end < len(versions),
+			"endCursor":   endCursor,
+		},
+	}, nil
+}
+
+// cursors encode the version number, which is stable as versions are
+// only ever added on top.
+func encodeCursor(number int) string {
+	return base64.StdEncoding.EncodeToString([]byte("version:" + strconv.Itoa(number)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	decoded, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(decoded), "version:") {
+		number, err := strconv.Atoi(strings.TrimPrefix(string(decoded), "version:"))
+		if err == nil {
+			return number, nil
+		}
+	}
+	return 0, errors.New("invalid after; after must be a cursor of a version")
+}
+
+type change struct {
+	Field  string  `json:"field"`
+	Type   string  `json:"type"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+// diff lists the mutable fields that differ between two versions, but
+// the omitted ones. Empty fields are null, so setting one is a create and
+// emptying it a delete.
+func diff(before model.Record, after model.Record, omit []string) []change {
+	beforeData := before.GetData()
+	afterData := after.GetData()
+
+	changes := []change{}
+	for _, field := range (model.Record{}).MutableFields() {
+		if contains(omit, field) {
+			continue
+		}
+		b := format(beforeData[field])
+		a := format(afterData[field])
+
+		switch {
+		case b == nil && a == nil:
+			continue
+		case b == nil:
+			changes = append(changes, change{field, "create", b, a})
+		case a == nil:
+			changes = append(changes, change{field, "delete", b, a})
+		case *a != *b:
+			changes = append(changes, change{field, "update", b, a})
+		}
+	}
+
+	return changes
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func format(value interface{}) *string {
+	var formatted string
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		formatted = v.Format(time.RFC3339)
+	case nil:
+		return nil
+	default:
+		formatted = fmt.Sprint(v)
+	}
+
+	if formatted == "" {
+		return nil
+	}
+	return &formatted
+}
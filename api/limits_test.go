@@ -0,0 +1,206 @@
+package api_test
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/limit"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+func TestLimits(t *testing.T) {
+	clock := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, pacific))
+	records := service.NewMemoryRecordService(clock)
+	a := api.NewAPI(&records, service.NewChangeFeed(), nil, nil, nil)
+	a.SetLimits("v1", limit.Limits{MaxBodyBytes: 64}, clock)
+	a.SetLimits("v2", limit.Limits{Rate: 0.5, Burst: 2}, clock)
+
+	router := mux.NewRouter()
+	a.CreateRoutes(router.PathPrefix("/api").Subrouter())
+	handler := middleware.AuthMiddleware(tokens{
+		"tt_ops":     {Subject: "apikey:ops", Scopes: []string{auth.ScopeAdmin}},
+		"tt_billing": {Subject: "apikey:billing", Scopes: []string{auth.ScopeAdmin}},
+	})(middleware.TenantMiddleware(router))
+
+	send := func(method string, path string, key string, ip string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = ip + ":4321"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	before := rejections("v2.rate_limited")
+
+	tests := []struct {
+		name  string
+		key   string
+		ip    string
+		wait  time.Duration
+		want  int
+		retry string
+	}{
+		{"first", "tt_ops", "10.0.0.1", 0, http.StatusBadRequest, ""},
+		{"burst", "tt_ops", "10.0.0.2", 0, http.StatusBadRequest, ""},
+		{"over the burst", "tt_ops", "10.0.0.1", 0, http.StatusTooManyRequests, "2"},
+		{"other key", "tt_billing", "10.0.0.1", 0, http.StatusBadRequest, ""},
+		{"refilled in part", "tt_ops", "10.0.0.1", time.Second, http.StatusTooManyRequests, "1"},
+		{"refilled", "tt_ops", "10.0.0.1", time.Second, http.StatusBadRequest, ""},
+		{"anonymous", "", "10.0.0.1", 0, http.StatusUnauthorized, ""},
+		{"anonymous burst", "", "10.0.0.1", 0, http.StatusUnauthorized, ""},
+		{"anonymous over the burst", "", "10.0.0.1", 0, http.StatusTooManyRequests, "2"},
+		{"other ip", "", "10.0.0.2", 0, http.StatusUnauthorized, ""},
+	}
+	for _, test := range tests {
+		clock.Advance(test.wait)
+		w := send("GET", "/api/v2/records/32", test.key, test.ip, "")
+		if w.Code != test.want || w.Header().Get("Retry-After") != test.retry {
+			t.Errorf("%s: GET /api/v2/records/32 = %d, Retry-After %q, %s, want %d, %q",
+				test.name, w.Code, w.Header().Get("Retry-After"), w.Body.String(), test.want, test.retry)
+		}
+	}
+	if rejected := rejections("v2.rate_limited") - before; rejected != 3 {
+		t.Errorf("%d requests counted as rate limited, want 3", rejected)
+	}
+
+	// v1 has its own limits
+	w := send("POST", "/api/v1/records/30", "tt_ops", "10.0.0.1", `{"first_name":"Steve"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("POST /api/v1/records/30 = %d %s, want 200", w.Code, w.Body.String())
+	}
+	w = send("POST", "/api/v1/records/30", "tt_ops", "10.0.0.1", `{"first_name":"`+strings.Repeat("e", 64)+`"}`)
+	if w.Code != http.StatusRequestEntityTooLarge ||
+		w.Body.String() != `{"error":"request body too large; the limit is 64 bytes"}`+"\n" {
+		t.Errorf("POST /api/v1/records/30 with a large body = %d %s, want 413", w.Code, w.Body.String())
+	}
+
+	// bodies without a length are cut at the limit as well
+	req := httptest.NewRequest("POST", "/api/v1/records/30", strings.NewReader(strings.Repeat(" ", 65)+"{}"))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /api/v1/records/30 with a chunked body = %d %s, want 413", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	middleware.MetricsHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `"v1.body_too_large": 2`) {
+		t.Errorf("GET /metrics = %s, want 2 bodies too large for v1", w.Body.String())
+	}
+}
+
+// rejections is how many requests were rejected for key so far.
+func rejections(key string) int64 {
+	count, ok := limit.Rejections.Get(key).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return count.Value()
+}
+
+func TestAuthFailureLimits(t *testing.T) {
+	clock := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, pacific))
+	records := service.NewMemoryRecordService(clock)
+	a := api.NewAPI(&records, service.NewChangeFeed(), nil, nil, nil)
+
+	router := mux.NewRouter()
+	a.CreateRoutes(router.PathPrefix("/api").Subrouter())
+	failures := limit.NewLimiter(limit.Limits{Rate: 0.5, Burst: 2}, clock)
+	handler := middleware.AuthFailureLimitMiddleware(failures)(middleware.AuthMiddleware(tokens{
+		"tt_ops": {Subject: "apikey:ops", Scopes: []string{auth.ScopeAdmin}},
+	})(middleware.TenantMiddleware(router)))
+
+	before := rejections("auth.rate_limited")
+
+	tests := []struct {
+		name  string
+		key   string
+		ip    string
+		wait  time.Duration
+		want  int
+		retry string
+	}{
+		{"valid key", "tt_ops", "10.0.0.1", 0, http.StatusBadRequest, ""},
+		{"valid key again", "tt_ops", "10.0.0.1", 0, http.StatusBadRequest, ""},
+		{"valid key over the burst", "tt_ops", "10.0.0.1", 0, http.StatusBadRequest, ""},
+		{"invalid key", "tt_guess", "10.0.0.1", 0, http.StatusUnauthorized, ""},
+		{"no key", "", "10.0.0.1", 0, http.StatusUnauthorized, ""},
+		{"invalid keys over the burst", "tt_guess", "10.0.0.1", 0, http.StatusTooManyRequests, "2"},
+		{"valid key of the same ip", "tt_ops", "10.0.0.1", 0, http.StatusTooManyRequests, "2"},
+		{"other ip", "tt_guess", "10.0.0.2", 0, http.StatusUnauthorized, ""},
+		{"refilled", "tt_ops", "10.0.0.1", 2 * time.Second, http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		clock.Advance(test.wait)
+		req := httptest.NewRequest("GET", "/api/v2/records/32", nil)
+		req.RemoteAddr = test.ip + ":4321"
+		if test.key != "" {
+			req.Header.Set("X-API-Key", test.key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.want || w.Header().Get("Retry-After") != test.retry {
+			t.Errorf("%s: GET /api/v2/records/32 = %d, Retry-After %q, %s, want %d, %q",
+				test.name, w.Code, w.Header().Get("Retry-After"), w.Body.String(), test.want, test.retry)
+		}
+	}
+	if rejected := rejections("auth.rate_limited") - before; rejected != 2 {
+		t.Errorf("%d requests counted as rate limited, want 2", rejected)
+	}
+}
+
+// TestAuthFailureLimitsInFlight keeps more requests authenticating fine
+// in flight at once than the burst of failures.
+func TestAuthFailureLimitsInFlight(t *testing.T) {
+	clock := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, pacific))
+	failures := limit.NewLimiter(limit.Limits{Rate: 0.5, Burst: 2}, clock)
+
+	const inFlight = 5
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	handler := middleware.AuthFailureLimitMiddleware(failures)(middleware.AuthMiddleware(tokens{
+		"tt_ops": {Subject: "apikey:ops", Scopes: []string{auth.ScopeAdmin}},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	})))
+
+	codes := make(chan int, inFlight)
+	for i := 0; i < inFlight; i++ {
+		go func() {
+			req := httptest.NewRequest("GET", "/api/v2/records/32", nil)
+			req.RemoteAddr = "10.0.0.1:4321"
+			req.Header.Set("X-API-Key", "tt_ops")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			codes <- w.Code
+		}()
+	}
+	for arrivals := 0; arrivals < inFlight; {
+		select {
+		case <-arrived:
+			arrivals++
+		case code := <-codes:
+			t.Fatalf("request turned away with %d while the others were in flight", code)
+		}
+	}
+	close(release)
+	for i := 0; i < inFlight; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("request in flight = %d, want 200", code)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package openapi describes the api routes as an OpenAPI 3 document.
+//
+// Each api version lists its routes as a []Route, which is used both to
+// register the handlers with the router and to generate the document, so
+// the two can't drift apart.
+package openapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Route is a handler along with its OpenAPI description.
+type Route struct {
+	Method      string
+	Path        string
+	Handler     http.HandlerFunc
+	OperationID string
+	Summary     string
+	Parameters  []Parameter
+
+	// RequestBody and Response are the schemas of the json bodies,
+	// nil if there is none.
+	RequestBody *Schema
+	Response    *Schema
+
+	// ContentType is the media type of Response, json when empty.
+	ContentType string
+
+	// JSONAPI is set when the route negotiates JSON:API documents.
+	JSONAPI bool
+
+	// Scope is required from the caller, the route is public when empty.
+	Scope string
+}
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lowercase http method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+
+	// Security lists the scopes required by scheme name.
+	Security []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+const (
+	jsonContentType    = "application/json"
+	jsonAPIContentType = "application/vnd.api+json"
+)
+
+const bearerScheme = "bearer"
+
+// NewDocument returns a document with the shared component schemas.
+func NewDocument(title string, version string) *Document {
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{title, version},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: map[string]*Schema{
+				"Record":          RecordSchema(),
+				"RecordData":      RecordDataSchema(),
+				"Error":           ErrorSchema(),
+				"JSONAPIDocument": JSONAPIDocumentSchema(),
+				"JSONAPIErrors":   JSONAPIErrorsSchema(),
+				"Webhook":         WebhookSchema(),
+				"DeadLetter":      DeadLetterSchema(),
+				"APIKey":          APIKeySchema(),
+				"Attestation":     AttestationSchema(),
+				"Erasure":         ErasureSchema(),
+				"Maintenance":     MaintenanceSchema(),
+				"Verification":    VerificationSchema(),
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerScheme: {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "an api key, also accepted in the X-API-Key header",
+				},
+			},
+		},
+	}
+}
+
+// AddRoutes describes routes mounted under prefix.
+func (d *Document) AddRoutes(prefix string, routes []Route) {
+	for _, route := range routes {
+		path := prefix + route.Path
+		item, ok := d.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			d.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = newOperation(route)
+	}
+}
+
+// Operation returns the operation of method on path, or nil.
+func (d *Document) Operation(method string, path string) *Operation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+	return (*item)[strings.ToLower(method)]
+}
+
+func newOperation(route Route) *Operation {
+	operation := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Parameters:  route.Parameters,
+		Responses:   map[string]Response{},
+	}
+
+	if route.RequestBody != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{jsonContentType: {route.RequestBody}},
+		}
+	}
+
+	ok := Response{Description: "OK"}
+	if route.Response != nil {
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = jsonContentType
+		}
+		ok.Content = map[string]MediaType{contentType: {route.Response}}
+	}
+	errorContent := map[string]MediaType{jsonContentType: {Ref("Error")}}
+	if route.JSONAPI {
+		if ok.Content == nil {
+			ok.Content = map[string]MediaType{}
+		}
+		ok.Content[jsonAPIContentType] = MediaType{Ref("JSONAPIDocument")}
+		errorContent[jsonAPIContentType] = MediaType{Ref("JSONAPIErrors")}
+		if operation.RequestBody != nil {
+			operation.RequestBody.Content[jsonAPIContentType] = MediaType{
+				JSONAPIDocumentSchema(),
+			}
+		}
+	}
+	operation.Responses["200"] = ok
+
+	if len(route.Parameters) > 0 || route.RequestBody != nil {
+		operation.Responses["400"] = Response{
+			Description: "Invalid request, or the record does not exist",
+			Content:     errorContent,
+		}
+	}
+	if route.Scope != "" {
+		operation.Security = []map[string][]string{{bearerScheme: {route.Scope}}}
+		operation.Responses["401"] = Response{
+			Description: "Missing or invalid credentials",
+			Content:     errorContent,
+		}
+		operation.Responses["403"] = Response{
+			Description: "The credentials lack the " + route.Scope + " scope",
+			Content:     errorContent,
+		}
+	}
+	if route.RequestBody != nil {
+		operation.Responses["500"] = Response{
+			Description: "Internal error",
+			Content:     errorContent,
+		}
+	}
+
+	return operation
+}
+
+// IDParameter is the positive integer id of a record in the path.
+func IDParameter() Parameter {
+	return Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "id of the record",
+		Required:    true,
+		Schema:      &Schema{Type: "integer", Minimum: float(1), Maximum: float(1<<31 - 1)},
+	}
+}
+
+// WebhookIDParameter is the positive integer id of a webhook in the path.
+func WebhookIDParameter() Parameter {
+	param := IDParameter()
+	param.Description = "id of the webhook"
+	return param
+}
+
+// APIKeyIDParameter is the positive integer id of an api key in the path.
+func APIKeyIDParameter() Parameter {
+	param := IDParameter()
+	param.Description = "id of the api key"
+	return param
+}
+
+// IDQueryParameter is the optional id of a record to filter on.
+func IDQueryParameter() Parameter {
+	param := IDParameter()
+	param.In = "query"
+	param.Required = false
+	param.Description = "only the record with this id"
+	return param
+}
+
+// AtParameter is the optional RFC3339 time of a time travel lookup.
+func AtParameter() Parameter {
+	return Parameter{
+		Name:        "at",
+		In:          "query",
+		Description: "look the record up as it was at this RFC3339 time",
+		Schema:      &Schema{Type: "string", Format: "date-time"},
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
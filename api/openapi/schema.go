@@ -0,0 +1,336 @@
+package openapi
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/gobeam/stringy"
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// Schema is the subset of the OpenAPI schema object used by the api.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+// Ref refers to a schema of the document's components.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// ArrayOf is a list of items.
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// RecordSchema is the schema of model.RecordJSON.
+func RecordSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"id", "data"},
+		Properties: map[string]*Schema{
+			"id":   {Type: "integer"},
+			"data": Ref("RecordData"),
+		},
+	}
+}
+
+// RecordDataSchema is the schema of the data of model.RecordJSON,
+// derived from the fields of model.Record the same way as ToJSON.
+func RecordDataSchema() *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	t := reflect.TypeOf(model.Record{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !model.IsDataField(field.Name) {
+			continue
+		}
+		fieldKey := stringy.New(field.Name).SnakeCase().ToLower()
+		schema.Properties[fieldKey] = schemaOf(field.Type)
+		// /api/v1 leaves out the fields added along with /api/v2
+		if fieldKey != "actor" && fieldKey != "hash" {
+			schema.Required = append(schema.Required, fieldKey)
+		}
+	}
+
+	// erased versions have their mutable fields redacted, see model.Redacted
+	schema.Properties["erased_at"] = &Schema{Type: "string", Format: "date-time"}
+
+	return schema
+}
+
+// RecordPayloadSchema is the schema of the body posted to create or
+// update a record: any of its mutable fields, where null deletes it.
+// Unknown fields are ignored by the api, so they are allowed.
+func RecordPayloadSchema() *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	t := reflect.TypeOf(model.Record{})
+	for _, field := range (model.Record{}).MutableFields() {
+		fieldKey := stringy.New(field).CamelCase("?", "").UcFirst()
+		structField, ok := t.FieldByName(fieldKey)
+		if !ok {
+			continue
+		}
+		property := schemaOf(structField.Type)
+		property.Nullable = true
+		schema.Properties[field] = property
+	}
+
+	return schema
+}
+
+// WebhookSchema is the schema of a webhook.Subscription.
+func WebhookSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"id", "url", "fields", "created_at"},
+		Properties: map[string]*Schema{
+			"id":         {Type: "integer"},
+			"url":        {Type: "string"},
+			"fields":     ArrayOf(&Schema{Type: "string"}),
+			"created_at": {Type: "string", Format: "date-time"},
+		},
+	}
+}
+
+// WebhookPayloadSchema is the schema of the body posted to subscribe a
+// url. An empty list of fields subscribes to every change.
+func WebhookPayloadSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"url", "secret"},
+		Properties: map[string]*Schema{
+			"url":    {Type: "string"},
+			"secret": {Type: "string"},
+			"fields": ArrayOf(&Schema{Type: "string"}),
+		},
+	}
+}
+
+// DeadLetterSchema is the schema of a webhook delivery that ran out of
+// attempts, along with the payload it failed to deliver.
+func DeadLetterSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":              {Type: "integer"},
+			"subscription_id": {Type: "integer"},
+			"record_id":       {Type: "integer"},
+			"event":           {Type: "string"},
+			"status":          {Type: "string"},
+			"attempts":        {Type: "integer"},
+			"last_error":      {Type: "string"},
+			"next_attempt_at": {Type: "string", Format: "date-time"},
+			"created_at":      {Type: "string", Format: "date-time"},
+			"payload":         {Type: "object"},
+		},
+	}
+}
+
+// APIKeySchema is the schema of an apikey.Key. key is the secret, only
+// returned when the key is issued.
+func APIKeySchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"id", "name", "prefix", "scopes", "roles", "tenant", "created_at"},
+		Properties: map[string]*Schema{
+			"id":         {Type: "integer"},
+			"name":       {Type: "string"},
+			"prefix":     {Type: "string"},
+			"scopes":     ArrayOf(&Schema{Type: "string"}),
+			"roles":      ArrayOf(&Schema{Type: "string"}),
+			"tenant":     {Type: "string"},
+			"created_at": {Type: "string", Format: "date-time"},
+			"revoked_at": {Type: "string", Format: "date-time", Nullable: true},
+			"key":        {Type: "string"},
+		},
+	}
+}
+
+// APIKeyPayloadSchema is the schema of the body posted to issue a key.
+func APIKeyPayloadSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"name", "scopes"},
+		Properties: map[string]*Schema{
+			"name":   {Type: "string"},
+			"scopes": ArrayOf(&Schema{Type: "string"}),
+			"roles":  ArrayOf(&Schema{Type: "string"}),
+			"tenant": {Type: "string"},
+		},
+	}
+}
+
+// ErasureSchema is the schema of a model.Erasure.
+func ErasureSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"id", "record_id", "actor", "reason", "versions", "erased_at"},
+		Properties: map[string]*Schema{
+			"id":        {Type: "integer"},
+			"record_id": {Type: "integer"},
+			"actor":     {Type: "string"},
+			"reason":    {Type: "string"},
+			"versions":  {Type: "integer"},
+			"erased_at": {Type: "string", Format: "date-time"},
+		},
+	}
+}
+
+// ErasurePayloadSchema is the schema of the body posted to erase a
+// record.
+func ErasurePayloadSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"reason"},
+		Properties: map[string]*Schema{
+			"reason": {Type: "string"},
+		},
+	}
+}
+
+// MaintenanceSchema is the schema of a model.Maintenance.
+func MaintenanceSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"id", "record_id", "actor", "operation", "reason", "versions", "performed_at"},
+		Properties: map[string]*Schema{
+			"id":           {Type: "integer"},
+			"record_id":    {Type: "integer"},
+			"actor":        {Type: "string"},
+			"operation":    {Type: "string"},
+			"reason":       {Type: "string"},
+			"versions":     {Type: "integer"},
+			"performed_at": {Type: "string", Format: "date-time"},
+		},
+	}
+}
+
+// CorrectionPayloadSchema is the schema of the body posted to correct
+// the version of a record updated at updated_at, whose data holds the
+// corrected fields the same way as RecordPayloadSchema.
+func CorrectionPayloadSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"updated_at", "reason", "data"},
+		Properties: map[string]*Schema{
+			"updated_at": {Type: "string", Format: "date-time"},
+			"reason":     {Type: "string"},
+			"data":       RecordPayloadSchema(),
+		},
+	}
+}
+
+// VerificationSchema is the schema of a chain.Report.
+func VerificationSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"record_id", "versions", "unchained", "valid"},
+		Properties: map[string]*Schema{
+			"record_id": {Type: "integer"},
+			"versions":  {Type: "integer"},
+			"unchained": {Type: "integer"},
+			"valid":     {Type: "boolean"},
+			"broken": {
+				Type:     "object",
+				Required: []string{"updated_at", "reason"},
+				Properties: map[string]*Schema{
+					"updated_at": {Type: "string", Format: "date-time"},
+					"reason":     {Type: "string"},
+				},
+			},
+		},
+	}
+}
+
+// AttestationSchema is the schema of an attest.Attestation, whose
+// signature covers the canonical json encoding of its statement.
+func AttestationSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"statement", "signature"},
+		Properties: map[string]*Schema{
+			"statement": {
+				Type:     "object",
+				Required: []string{"record", "omitted", "hash", "at", "issued_at", "key_id"},
+				Properties: map[string]*Schema{
+					"record":    Ref("Record"),
+					"omitted":   ArrayOf(&Schema{Type: "string"}),
+					"hash":      {Type: "string"},
+					"at":        {Type: "string", Format: "date-time"},
+					"issued_at": {Type: "string", Format: "date-time"},
+					"key_id":    {Type: "string"},
+				},
+			},
+			"signature": {Type: "string", Format: "byte"},
+		},
+	}
+}
+
+// JSONAPIDocumentSchema is a JSON:API document whose primary data is a
+// record resource, or a list of them.
+func JSONAPIDocumentSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"data"},
+		Properties: map[string]*Schema{
+			"data":  {Type: "object"},
+			"links": {Type: "object"},
+		},
+	}
+}
+
+// JSONAPIErrorsSchema is a JSON:API errors document.
+func JSONAPIErrorsSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"errors"},
+		Properties: map[string]*Schema{
+			"errors": ArrayOf(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"status": {Type: "string"},
+					"detail": {Type: "string"},
+				},
+			}),
+		},
+	}
+}
+
+// ErrorSchema is the schema of the errors written by concern/response.
+func ErrorSchema() *Schema {
+	return &Schema{
+		Type:       "object",
+		Required:   []string{"error"},
+		Properties: map[string]*Schema{"error": {Type: "string"}},
+	}
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
@@ -0,0 +1,134 @@
+package openapi
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ValidationError describes a value that doesn't match its schema.
+// Its message is meant to be shown to the api client.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{fmt.Sprintf(format, args...)}
+}
+
+// Parse validates the raw value of a path or query parameter, and
+// converts it to an int64, time.Time or string depending on its schema.
+func (p Parameter) Parse(raw string) (interface{}, error) {
+	switch {
+	case p.Schema.Type == "integer":
+		number, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || !p.Schema.inRange(float64(number)) {
+			if p.Schema.Minimum != nil && *p.Schema.Minimum == 1 {
+				return nil, invalid("invalid %s; %s must be a positive number", p.Name, p.Name)
+			}
+			return nil, invalid("invalid %s; %s must be an integer", p.Name, p.Name)
+		}
+		return number, nil
+	case p.Schema.Format == "date-time":
+		parsedTime, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, invalid("invalid time; time must be in RFC3339 format")
+		}
+		return parsedTime, nil
+	default:
+		return raw, nil
+	}
+}
+
+// ValidateBody checks a decoded json request body against the schema.
+// Properties that aren't described by the schema are allowed.
+func (s *Schema) ValidateBody(body interface{}) error {
+	object, ok := body.(map[string]interface{})
+	if s.Type == "object" && !ok {
+		return invalid("invalid input; body must be a json object")
+	}
+
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return invalid("invalid input; %s is required", name)
+		}
+	}
+
+	for name, value := range object {
+		property, ok := s.Properties[name]
+		if !ok {
+			continue
+		}
+		err := property.validateValue(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateValue(name string, value interface{}) error {
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return invalid("invalid input; %s can't be null", name)
+	}
+
+	switch s.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return invalid("invalid input; %s must be a string", name)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return invalid("invalid input; %s must be in RFC3339 format", name)
+			}
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || !s.inRange(number) {
+			return invalid("invalid input; %s must be a %s", name, s.Type)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("invalid input; %s must be a boolean", name)
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return invalid("invalid input; %s must be an object", name)
+		}
+		return s.ValidateBody(value)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return invalid("invalid input; %s must be an array", name)
+		}
+		if s.Items == nil {
+			break
+		}
+		for _, item := range items {
+			if err := s.Items.validateValue(name+" items", item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) inRange(number float64) bool {
+	if s.Minimum != nil && number < *s.Minimum {
+		return false
+	}
+	if s.Maximum != nil && number > *s.Maximum {
+		return false
+	}
+	return true
+}
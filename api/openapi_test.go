@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api"
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/webhook"
+)
+
+// TestOpenAPICoversRoutes fails when a route is registered without
+// being described in /api/openapi.json.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	records := service.NewMemoryRecordService(clock.Real())
+	// the webhook routes are described without touching the database
+	webhooks := webhook.NewStore(nil, clock.Real())
+	keys := apikey.NewStore(nil, clock.Real())
+	signer := attest.Signer{}
+	router := mux.NewRouter()
+	api.NewAPI(&records, service.NewChangeFeed(), &webhooks, &keys, &signer).CreateRoutes(router.PathPrefix("/api").Subrouter())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json = %d", w.Code)
+	}
+
+	var doc openapi.Document
+	err := json.Unmarshal(w.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := 0
+	err = router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			// routes matching any method must at least document GET
+			methods = []string{"GET"}
+		}
+
+		for _, method := range methods {
+			routes++
+			if doc.Operation(method, path) == nil {
+				t.Errorf("%s %s is missing from the OpenAPI document", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routes == 0 {
+		t.Fatal("no routes were registered")
+	}
+}
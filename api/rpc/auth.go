@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/limit"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodScopes are the scopes required by each method, as on the
+// matching http routes.
+var methodScopes = map[string][]string{
+	Records_GetRecord_FullMethodName:    {auth.ScopeRead},
+	Records_UpsertRecord_FullMethodName: {auth.ScopeWrite},
+	Records_ListVersions_FullMethodName: {auth.ScopeHistory},
+	Records_WatchRecords_FullMethodName: {auth.ScopeHistory},
+}
+
+// AuthInterceptors authenticate the token of calls, sent in the
+// `authorization: Bearer <token>` or `x-api-key` metadata, and check the
+// scopes of the method. A nil authenticator lets every call in. The
+// tenant of calls is resolved as over http, from the `x-tenant-id`
+// metadata.
+//
+// The failures to authenticate are charged to the ip of the call in
+// failures, if any, as AuthFailureLimitMiddleware does over http.
+func AuthInterceptors(authenticator auth.Authenticator, failures *limit.Limiter) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := authorizeLimited(ctx, authenticator, failures, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := authorizeLimited(ss.Context(), authenticator, failures, info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authenticatedStream{ss, ctx})
+		}),
+	}
+}
+
+// authorizeLimited authorizes a call, unless the ip of the call failed
+// to authenticate too often, and charges the failures to it. Without an
+// authenticator, no call can fail to authenticate.
+func authorizeLimited(ctx context.Context, authenticator auth.Authenticator, failures *limit.Limiter, method string) (context.Context, error) {
+	if failures == nil || authenticator == nil {
+		return authorize(ctx, authenticator, method)
+	}
+
+	ip := ipOf(ctx)
+	wait, ok := failures.Check(ip)
+	if !ok {
+		limit.Rejections.Add("auth.rate_limited", 1)
+		return nil, tooManyRequests(wait)
+	}
+	ctx, err := authorize(ctx, authenticator, method)
+	if status.Code(err) == codes.Unauthenticated {
+		failures.Charge(ip)
+	}
+	return ctx, err
+}
+
+func authorize(ctx context.Context, authenticator auth.Authenticator, method string) (context.Context, error) {
+	principal := auth.Anonymous
+	md, _ := metadata.FromIncomingContext(ctx)
+	if authenticator != nil {
+		token := ""
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = auth.BearerToken(values[0])
+		}
+		if values := md.Get("x-api-key"); token == "" && len(values) > 0 {
+			token = values[0]
+		}
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "unauthorized; credentials are required")
+		}
+
+		var err error
+		principal, err = authenticator.Authenticate(ctx, token)
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			return nil, status.Error(codes.Unauthenticated, "unauthorized; invalid credentials")
+		}
+		if err != nil {
+			logging.LogError(err)
+			return nil, status.Error(codes.Internal, response.ErrInternal.Error())
+		}
+	}
+
+	scopes := methodScopes[method]
+	if !principal.Can(scopes...) {
+		return nil, status.Error(codes.PermissionDenied,
+			"forbidden; requires the "+strings.Join(scopes, " and ")+" scope")
+	}
+
+	requested := ""
+	if values := md.Get(strings.ToLower(tenant.Header)); len(values) > 0 {
+		requested = values[0]
+	}
+	t, err := tenant.Resolve(principal, requested)
+	if tenant.IsForbidden(err) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return tenant.WithTenant(auth.WithPrincipal(ctx, principal), t), nil
+}
+
+// authenticatedStream carries the principal in the context of a stream.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
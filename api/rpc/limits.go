@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/limit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LimitInterceptors limit the calls of every caller to the rate of
+// limits, as LimitMiddleware does over http, and the size of the
+// messages received to limits.MaxBodyBytes. Streams count as one call.
+// They must follow AuthInterceptors, to tell callers apart by their
+// principal.
+func LimitInterceptors(limits limit.Limits, clock clock.Clock) []grpc.ServerOption {
+	limiter := limit.NewLimiter(limits, clock)
+	take := func(ctx context.Context) error {
+		wait, ok := limiter.Take(callerOf(ctx))
+		if !ok {
+			limit.Rejections.Add("grpc.rate_limited", 1)
+			return tooManyRequests(wait)
+		}
+		return nil
+	}
+
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := take(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := take(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+	if limits.MaxBodyBytes > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(int(limits.MaxBodyBytes)))
+	}
+	return options
+}
+
+// tooManyRequests is the error of the calls over the rate, telling when
+// to retry.
+func tooManyRequests(wait time.Duration) error {
+	return status.Error(codes.ResourceExhausted,
+		fmt.Sprintf("too many requests; retry after %ds", limit.RetryAfter(wait)))
+}
+
+// callerOf identifies the caller of a call for its rate limit: its
+// principal, or its ip when it is anonymous.
+func callerOf(ctx context.Context) string {
+	principal, ok := auth.FromContext(ctx)
+	if ok && principal.Subject != auth.Anonymous.Subject {
+		return "principal:" + principal.Tenant + "/" + principal.Subject
+	}
+	return ipOf(ctx)
+}
+
+// ipOf identifies the caller of a call by the ip of its connection.
+func ipOf(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "ip:"
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		ip = p.Addr.String()
+	}
+	return "ip:" + ip
+}
@@ -0,0 +1,405 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: api/rpc/records.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Record struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Data          *structpb.Struct       `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_api_rpc_records_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_api_rpc_records_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_api_rpc_records_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Record) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Record) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type GetRecordRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=at,proto3" json:"at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRecordRequest) Reset() {
+	*x = GetRecordRequest{}
+	mi := &file_api_rpc_records_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRecordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRecordRequest) ProtoMessage() {}
+
+func (x *GetRecordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_rpc_records_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRecordRequest.ProtoReflect.Descriptor instead.
+func (*GetRecordRequest) Descriptor() ([]byte, []int) {
+	return file_api_rpc_records_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetRecordRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *GetRecordRequest) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+type UpsertRecordRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Data          *structpb.Struct       `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertRecordRequest) Reset() {
+	*x = UpsertRecordRequest{}
+	mi := &file_api_rpc_records_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertRecordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertRecordRequest) ProtoMessage() {}
+
+func (x *UpsertRecordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_rpc_records_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertRecordRequest.ProtoReflect.Descriptor instead.
+func (*UpsertRecordRequest) Descriptor() ([]byte, []int) {
+	return file_api_rpc_records_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UpsertRecordRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UpsertRecordRequest) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ListVersionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVersionsRequest) Reset() {
+	*x = ListVersionsRequest{}
+	mi := &file_api_rpc_records_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVersionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVersionsRequest) ProtoMessage() {}
+
+func (x *ListVersionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_rpc_records_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVersionsRequest.ProtoReflect.Descriptor instead.
+func (*ListVersionsRequest) Descriptor() ([]byte, []int) {
+	return file_api_rpc_records_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListVersionsRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListVersionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Versions      []*Record              `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVersionsResponse) Reset() {
+	*x = ListVersionsResponse{}
+	mi := &file_api_rpc_records_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVersionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVersionsResponse) ProtoMessage() {}
+
+func (x *ListVersionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_rpc_records_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVersionsResponse.ProtoReflect.Descriptor instead.
+func (*ListVersionsResponse) Descriptor() ([]byte, []int) {
+	return file_api_rpc_records_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListVersionsResponse) GetVersions() []*Record {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+type WatchRecordsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRecordsRequest) Reset() {
+	*x = WatchRecordsRequest{}
+	mi := &file_api_rpc_records_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRecordsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRecordsRequest) ProtoMessage() {}
+
+func (x *WatchRecordsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_rpc_records_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRecordsRequest.ProtoReflect.Descriptor instead.
+func (*WatchRecordsRequest) Descriptor() ([]byte, []int) {
+	return file_api_rpc_records_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchRecordsRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+var File_api_rpc_records_proto protoreflect.FileDescriptor
+
+const file_api_rpc_records_proto_rawDesc = "" +
+	"\n" +
+	"\x15api/rpc/records.proto\x12\rtimetravel.v2\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"E\n" +
+	"\x06Record\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12+\n" +
+	"\x04data\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x04data\"N\n" +
+	"\x10GetRecordRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12*\n" +
+	"\x02at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\"R\n" +
+	"\x13UpsertRecordRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12+\n" +
+	"\x04data\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x04data\"%\n" +
+	"\x13ListVersionsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\"I\n" +
+	"\x14ListVersionsResponse\x121\n" +
+	"\bversions\x18\x01 \x03(\v2\x15.timetravel.v2.RecordR\bversions\"%\n" +
+	"\x13WatchRecordsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id2\xbf\x02\n" +
+	"\aRecords\x12C\n" +
+	"\tGetRecord\x12\x1f.timetravel.v2.GetRecordRequest\x1a\x15.timetravel.v2.Record\x12I\n" +
+	"\fUpsertRecord\x12\".timetravel.v2.UpsertRecordRequest\x1a\x15.timetravel.v2.Record\x12W\n" +
+	"\fListVersions\x12\".timetravel.v2.ListVersionsRequest\x1a#.timetravel.v2.ListVersionsResponse\x12K\n" +
+	"\fWatchRecords\x12\".timetravel.v2.WatchRecordsRequest\x1a\x15.timetravel.v2.Record0\x01B*Z(github.com/rainbowmga/timetravel/api/rpcb\x06proto3"
+
+var (
+	file_api_rpc_records_proto_rawDescOnce sync.Once
+	file_api_rpc_records_proto_rawDescData []byte
+)
+
+func file_api_rpc_records_proto_rawDescGZIP() []byte {
+	file_api_rpc_records_proto_rawDescOnce.Do(func() {
+		file_api_rpc_records_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_rpc_records_proto_rawDesc), len(file_api_rpc_records_proto_rawDesc)))
+	})
+	return file_api_rpc_records_proto_rawDescData
+}
+
+var file_api_rpc_records_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_api_rpc_records_proto_goTypes = []any{
+	(*Record)(nil),                // 0: timetravel.v2.Record
+	(*GetRecordRequest)(nil),      // 1: timetravel.v2.GetRecordRequest
+	(*UpsertRecordRequest)(nil),   // 2: timetravel.v2.UpsertRecordRequest
+	(*ListVersionsRequest)(nil),   // 3: timetravel.v2.ListVersionsRequest
+	(*ListVersionsResponse)(nil),  // 4: timetravel.v2.ListVersionsResponse
+	(*WatchRecordsRequest)(nil),   // 5: timetravel.v2.WatchRecordsRequest
+	(*structpb.Struct)(nil),       // 6: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_api_rpc_records_proto_depIdxs = []int32{
+	6, // 0: timetravel.v2.Record.data:type_name -> google.protobuf.Struct
+	7, // 1: timetravel.v2.GetRecordRequest.at:type_name -> google.protobuf.Timestamp
+	6, // 2: timetravel.v2.UpsertRecordRequest.data:type_name -> google.protobuf.Struct
+	0, // 3: timetravel.v2.ListVersionsResponse.versions:type_name -> timetravel.v2.Record
+	1, // 4: timetravel.v2.Records.GetRecord:input_type -> timetravel.v2.GetRecordRequest
+	2, // 5: timetravel.v2.Records.UpsertRecord:input_type -> timetravel.v2.UpsertRecordRequest
+	3, // 6: timetravel.v2.Records.ListVersions:input_type -> timetravel.v2.ListVersionsRequest
+	5, // 7: timetravel.v2.Records.WatchRecords:input_type -> timetravel.v2.WatchRecordsRequest
+	0, // 8: timetravel.v2.Records.GetRecord:output_type -> timetravel.v2.Record
+	0, // 9: timetravel.v2.Records.UpsertRecord:output_type -> timetravel.v2.Record
+	4, // 10: timetravel.v2.Records.ListVersions:output_type -> timetravel.v2.ListVersionsResponse
+	0, // 11: timetravel.v2.Records.WatchRecords:output_type -> timetravel.v2.Record
+	8, // [8:12] is the sub-list for method output_type
+	4, // [4:8] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_api_rpc_records_proto_init() }
+func file_api_rpc_records_proto_init() {
+	if File_api_rpc_records_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_rpc_records_proto_rawDesc), len(file_api_rpc_records_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_rpc_records_proto_goTypes,
+		DependencyIndexes: file_api_rpc_records_proto_depIdxs,
+		MessageInfos:      file_api_rpc_records_proto_msgTypes,
+	}.Build()
+	File_api_rpc_records_proto = out.File
+	file_api_rpc_records_proto_goTypes = nil
+	file_api_rpc_records_proto_depIdxs = nil
+}
@@ -0,0 +1,205 @@
+// Package rpc serves the v2 records api over gRPC. The service is
+// described in records.proto, regenerate the code after changing it with
+// `make proto`.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxID is the largest id accepted by the http api.
+const maxID = 1<<31 - 1
+
+type Server struct {
+	UnimplementedRecordsServer
+
+	records service.RecordService
+	feed    *service.ChangeFeed
+}
+
+// NewServer serves the records, and streams the versions published to
+// feed to WatchRecords calls.
+func NewServer(records service.RecordService, feed *service.ChangeFeed) *Server {
+	return &Server{records: records, feed: feed}
+}
+
+// Register adds the Records service to a gRPC server.
+func (s *Server) Register(srv *grpc.Server) {
+	RegisterRecordsServer(srv, s)
+}
+
+func (s *Server) GetRecord(ctx context.Context, req *GetRecordRequest) (*Record, error) {
+	id, err := validID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	var record model.Record
+	if req.GetAt() != nil {
+		if err := req.GetAt().CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid time; "+err.Error())
+		}
+		record, err = s.records.GetRecordAt(ctx, id, req.GetAt().AsTime())
+	} else {
+		record, err = s.records.GetRecord(ctx, id)
+	}
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	return toRecord(record, auth.Unreadable(ctx))
+}
+
+// UpsertRecord creates the record if it doesn't exist, like
+// POST /api/v2/records/{id}.
+func (s *Server) UpsertRecord(ctx context.Context, req *UpsertRecordRequest) (*Record, error) {
+	id, err := validID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	data := req.GetData().AsMap()
+	if err := openapi.RecordPayloadSchema().ValidateBody(data); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	principal, _ := auth.FromContext(ctx)
+	if fields := principal.Unwritable(data); len(fields) > 0 {
+		return nil, status.Error(codes.PermissionDenied, auth.ForbiddenFields(fields))
+	}
+
+	record, err := s.records.GetRecord(ctx, id)
+	if err == nil {
+		log.Info().Msg("Update Existing Record")
+		record, err = s.records.UpdateRecord(ctx, record, data)
+	} else if errors.Is(err, service.ErrRecordDoesNotExist) {
+		log.Info().Msg("Create New Record")
+		record, err = s.records.CreateRecord(ctx, id, data)
+	}
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	return toRecord(record, auth.Unreadable(ctx))
+}
+
+func (s *Server) ListVersions(ctx context.Context, req *ListVersionsRequest) (*ListVersionsResponse, error) {
+	id, err := validID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.records.GetVersions(ctx, id)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	omit := auth.Unreadable(ctx)
+	resp := &ListVersionsResponse{Versions: make([]*Record, 0, len(records))}
+	for _, record := range records {
+		version, err := toRecord(record, omit)
+		if err != nil {
+			return nil, err
+		}
+		resp.Versions = append(resp.Versions, version)
+	}
+	return resp, nil
+}
+
+// WatchRecords streams new versions until the client cancels. The stream
+// ends with UNAVAILABLE if the client falls too far behind, in which case
+// it should catch up with ListVersions and watch again.
+func (s *Server) WatchRecords(req *WatchRecordsRequest, stream grpc.ServerStreamingServer[Record]) error {
+	if req.GetId() > maxID {
+		return status.Error(codes.InvalidArgument, "invalid id; id must be a positive number")
+	}
+
+	omit := auth.Unreadable(stream.Context())
+	changes, cancel := s.feed.Subscribe(stream.Context(), uint(req.GetId()), 0)
+	defer cancel()
+
+	// the headers tell the client that the watch has started
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case change, ok := <-changes:
+			if !ok {
+				return status.Error(codes.Unavailable, "watch fell behind; list the versions and watch again")
+			}
+			msg, err := toRecord(change.Record, omit)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func validID(id uint32) (uint, error) {
+	if id == 0 || id > maxID {
+		return 0, status.Error(codes.InvalidArgument, "invalid id; id must be a positive number")
+	}
+	return uint(id), nil
+}
+
+// statusOf maps RecordService errors to gRPC status codes.
+func statusOf(err error) error {
+	switch {
+	case errors.Is(err, service.ErrRecordDoesNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrRecordEmpty):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrTenantDoesNotExist):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		logging.LogError(err)
+		return status.Error(codes.Internal, response.ErrInternal.Error())
+	}
+}
+
+// toRecord converts a record to the same shape as its json representation,
+// leaving out the omitted fields.
+func toRecord(record model.Record, omit []string) (*Record, error) {
+	recordJSON, err := record.ToJSON(omit...)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	raw, err := json.Marshal(recordJSON.Data)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, statusOf(err)
+	}
+
+	value, err := structpb.NewStruct(data)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return &Record{Id: uint32(recordJSON.ID), Data: value}, nil
+}
@@ -0,0 +1,226 @@
+package rpc_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/api/rpc"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/limit"
+	"github.com/rainbowmga/timetravel/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newClient calls a server with options, which let every call in by
+// default.
+func newClient(t *testing.T, clock clock.Clock, options ...grpc.ServerOption) rpc.RecordsClient {
+	memory := service.NewMemoryRecordService(clock)
+	feed := service.NewChangeFeed()
+	records := service.NewPublishingRecordService(&memory, feed)
+
+	if len(options) == 0 {
+		options = rpc.AuthInterceptors(nil, nil)
+	}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(options...)
+	rpc.NewServer(&records, feed).Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return rpc.NewRecordsClient(conn)
+}
+
+func data(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	value, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestRecords(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+	clock := clock.NewFake(start)
+	client := newClient(t, clock)
+
+	_, err := client.GetRecord(ctx, &rpc.GetRecordRequest{Id: 30})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("got %v, want NotFound", err)
+	}
+
+	_, err = client.UpsertRecord(ctx, &rpc.UpsertRecordRequest{
+		Id:   30,
+		Data: data(t, map[string]interface{}{"first_name": 1}),
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", err)
+	}
+
+	_, err = client.UpsertRecord(ctx, &rpc.UpsertRecordRequest{
+		Id:   30,
+		Data: data(t, map[string]interface{}{"first_name": "Steve", "middle_name": "Paul"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	record, err := client.UpsertRecord(ctx, &rpc.UpsertRecordRequest{
+		Id:   30,
+		Data: data(t, map[string]interface{}{"first_name": "Steven", "middle_name": nil}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := record.GetData().AsMap()
+	if fields["first_name"] != "Steven" || fields["middle_name"] != "" {
+		t.Errorf("got %v after upsert", fields)
+	}
+
+	record, err = client.GetRecord(ctx, &rpc.GetRecordRequest{Id: 30, At: timestamppb.New(start)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := record.GetData().AsMap()["first_name"]; got != "Steve" {
+		t.Errorf("got first_name %v at %s, want Steve", got, start)
+	}
+
+	versions, err := client.ListVersions(ctx, &rpc.ListVersionsRequest{Id: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions.GetVersions()) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions.GetVersions()))
+	}
+	if got := versions.GetVersions()[0].GetData().AsMap()["updated_at"]; got != "2024-08-25T16:14:02Z" {
+		t.Errorf("got latest version at %v", got)
+	}
+}
+
+func TestWatchRecords(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC))
+	client := newClient(t, clock)
+
+	stream, err := client.WatchRecords(ctx, &rpc.WatchRecordsRequest{Id: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the subscription starts once the server received the call
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	upsert := func(id uint32, fields map[string]interface{}) {
+		clock.Advance(time.Second)
+		_, err := client.UpsertRecord(ctx, &rpc.UpsertRecordRequest{Id: id, Data: data(t, fields)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	upsert(30, map[string]interface{}{"first_name": "Steve"})
+	upsert(31, map[string]interface{}{"first_name": "Jane"})
+	upsert(30, map[string]interface{}{"first_name": "Steve"})
+	upsert(30, map[string]interface{}{"first_name": "Steven"})
+
+	for _, want := range []string{"Steve", "Steven"} {
+		record, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := record.GetData().AsMap()["first_name"]; record.GetId() != 30 || got != want {
+			t.Errorf("got record %d with first_name %v, want 30 with %s", record.GetId(), got, want)
+		}
+	}
+}
+
+// tokens authenticates the api keys of a map.
+type tokens map[string]auth.Principal
+
+func (t tokens) Authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	principal, ok := t[token]
+	if !ok {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+func TestLimits(t *testing.T) {
+	clock := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC))
+	authenticator := tokens{"tt_ops": {Subject: "apikey:ops", Scopes: []string{auth.ScopeRead, auth.ScopeWrite}}}
+	options := rpc.AuthInterceptors(authenticator, limit.NewLimiter(limit.Limits{Rate: 0.5, Burst: 2}, clock))
+	options = append(options, rpc.LimitInterceptors(limit.Limits{Rate: 0.5, Burst: 3, MaxBodyBytes: 256}, clock)...)
+	client := newClient(t, clock, options...)
+
+	call := func(key string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+		_, err := client.GetRecord(ctx, &rpc.GetRecordRequest{Id: 30})
+		return err
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want codes.Code
+	}{
+		{"first", "tt_ops", codes.NotFound},
+		{"second", "tt_ops", codes.NotFound},
+		{"burst", "tt_ops", codes.NotFound},
+		{"over the burst", "tt_ops", codes.ResourceExhausted},
+		{"invalid key", "tt_guess", codes.Unauthenticated},
+		{"invalid key burst", "tt_guess", codes.Unauthenticated},
+		{"invalid keys over the burst", "tt_guess", codes.ResourceExhausted},
+	}
+	for _, test := range tests {
+		err := call(test.key)
+		if status.Code(err) != test.want {
+			t.Errorf("%s: GetRecord = %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	// the bucket of the ip is empty, whatever the key
+	clock.Advance(2 * time.Second)
+	if err := call("tt_ops"); status.Code(err) != codes.NotFound {
+		t.Errorf("refilled: GetRecord = %v, want NotFound", err)
+	}
+	if err := call("tt_guess"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("refilled invalid key: GetRecord = %v, want Unauthenticated", err)
+	}
+	if err := call("tt_ops"); status.Code(err) != codes.ResourceExhausted ||
+		status.Convert(err).Message() != "too many requests; retry after 2s" {
+		t.Errorf("after invalid keys: GetRecord = %v, want ResourceExhausted", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "tt_ops")
+	_, err := client.UpsertRecord(ctx, &rpc.UpsertRecordRequest{
+		Id:   30,
+		Data: data(t, map[string]interface{}{"first_name": strings.Repeat("e", 256)}),
+	})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("UpsertRecord with a large message = %v, want ResourceExhausted", err)
+	}
+}
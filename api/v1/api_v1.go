@@ -2,9 +2,16 @@ package v1
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/middleware"
 	"github.com/rainbowmga/timetravel/service"
 )
 
+// v2Fields are the fields of records added along with /api/v2, which
+// v1 leaves out so that its responses stay the same.
+var v2Fields = []string{"actor", "hash"}
+
 type API_V1 struct {
 	records service.RecordService
 }
@@ -13,7 +20,37 @@ func NewV1API(records service.RecordService) *API_V1 {
 	return &API_V1{records}
 }
 
+// Routes lists the routes of v1 along with their OpenAPI description.
+func (a *API_V1) Routes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      "GET",
+			Path:        "/records/{id}",
+			Handler:     a.GetRecords,
+			OperationID: "getRecordV1",
+			Summary:     "Get the latest version of a record",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			Response:    openapi.Ref("Record"),
+			Scope:       auth.ScopeRead,
+		},
+		{
+			Method:      "POST",
+			Path:        "/records/{id}",
+			Handler:     a.PostRecords,
+			OperationID: "postRecordV1",
+			Summary:     "Create or update a record",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			RequestBody: openapi.RecordPayloadSchema(),
+			Response:    openapi.Ref("Record"),
+			Scope:       auth.ScopeWrite,
+		},
+	}
+}
+
 func (a *API_V1) CreateRoutes(routes *mux.Router) {
-	routes.Path("/records/{id}").HandlerFunc(a.GetRecords).Methods("GET")
-	routes.Path("/records/{id}").HandlerFunc(a.PostRecords).Methods("POST")
+	for _, route := range a.Routes() {
+		handler := middleware.ValidationMiddleware(route)(route.Handler)
+		handler = middleware.RequireScope(route.Scope)(handler)
+		routes.Path(route.Path).Handler(handler).Methods(route.Method)
+	}
 }
@@ -3,45 +3,32 @@ package v1
 import (
 	"fmt"
 	"net/http"
-	"strconv"
 
-	"github.com/gorilla/mux"
 	"github.com/rainbowmga/timetravel/concern/logging"
 	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
 )
 
 // GET /records/{id}
 // GetRecord retrieves the record.
 func (a *API_V1) GetRecords(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	id := mux.Vars(r)["id"]
-
-	idNumber, err := strconv.ParseInt(id, 10, 32)
-
-	if err != nil || idNumber <= 0 {
-		err := response.WriteError(
-			w,
-			"invalid id; id must be a positive number",
-			http.StatusBadRequest,
-		)
-		logging.LogError(err)
-		return
-	}
+	id := middleware.PathID(r)
 
 	record, err := a.records.GetRecord(
 		ctx,
-		uint(idNumber),
+		id,
 	)
 
 	if err != nil {
 		err := response.WriteError(
 			w,
-			fmt.Sprintf("record of id %v does not exist", idNumber),
+			fmt.Sprintf("record of id %v does not exist", id),
 			http.StatusBadRequest,
 		)
 		logging.LogError(err)
 		return
 	}
 
-	response.WriteRecord(w, record)
+	response.WriteRecord(w, r, record, v2Fields...)
 }
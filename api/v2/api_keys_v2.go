@@ -0,0 +1,180 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/middleware"
+)
+
+// apiKeyRoutes lists the routes managing api keys, which are only served
+// when the storage supports them.
+func (a *API_V2) apiKeyRoutes() []openapi.Route {
+	if a.keys == nil {
+		return nil
+	}
+
+	return []openapi.Route{
+		{
+			Method:      "POST",
+			Path:        "/api-keys",
+			Handler:     a.PostAPIKeys,
+			OperationID: "issueAPIKeyV2",
+			Summary:     "Issue an api key, returned only once",
+			RequestBody: openapi.APIKeyPayloadSchema(),
+			Response:    openapi.Ref("APIKey"),
+			Scope:       auth.ScopeAdmin,
+		},
+		{
+			Method:      "GET",
+			Path:        "/api-keys",
+			Handler:     a.GetAPIKeys,
+			OperationID: "listAPIKeysV2",
+			Summary:     "List the api keys, without their secrets",
+			Response:    openapi.ArrayOf(openapi.Ref("APIKey")),
+			Scope:       auth.ScopeAdmin,
+		},
+		{
+			Method:      "DELETE",
+			Path:        "/api-keys/{id}",
+			Handler:     a.DeleteAPIKeys,
+			OperationID: "revokeAPIKeyV2",
+			Summary:     "Revoke an api key",
+			Parameters:  []openapi.Parameter{openapi.APIKeyIDParameter()},
+			Response:    openapi.Ref("APIKey"),
+			Scope:       auth.ScopeAdmin,
+		},
+	}
+}
+
+type issuedKeyJSON struct {
+	apikey.Key
+	Secret string `json:"key"`
+}
+
+// POST /api-keys
+// issues an api key with the given scopes and roles, bound to a tenant
+// if any.
+// Admins bound to a tenant can only issue keys bound to it, and those
+// restricted by roles can only grant their own roles, which keys without
+// roles get.
+func (a *API_V2) PostAPIKeys(w http.ResponseWriter, r *http.Request) {
+	body := middleware.Body(r)
+
+	name, _ := body["name"].(string)
+	scopes := []string{}
+	if list, ok := body["scopes"].([]interface{}); ok {
+		for _, scope := range list {
+			scopes = append(scopes, scope.(string))
+		}
+	}
+	roles := []string{}
+	if list, ok := body["roles"].([]interface{}); ok {
+		for _, role := range list {
+			roles = append(roles, role.(string))
+		}
+	}
+	keyTenant, _ := body["tenant"].(string)
+
+	principal, _ := auth.FromContext(r.Context())
+	if ungrantable := principal.Ungrantable(roles); len(ungrantable) > 0 {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			auth.ForbiddenRoles(ungrantable),
+			http.StatusForbidden,
+		)
+		logging.LogError(err)
+		return
+	}
+	if len(roles) == 0 && principal.Fields != nil {
+		roles = append(roles, principal.Roles...)
+	}
+
+	if bound := boundTenant(r); bound != "" {
+		if keyTenant != "" && keyTenant != bound {
+			err := response.WriteErrorFor(
+				w,
+				r,
+				tenant.ErrForbiddenTenant.Error(),
+				http.StatusForbidden,
+			)
+			logging.LogError(err)
+			return
+		}
+		keyTenant = bound
+	}
+
+	key, secret, err := a.keys.Issue(r.Context(), name, scopes, roles, keyTenant)
+	if errors.Is(err, apikey.ErrNameRequired) || errors.Is(err, apikey.ErrInvalidScope) ||
+		errors.Is(err, apikey.ErrInvalidRole) || errors.Is(err, apikey.ErrInvalidTenant) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			"invalid input; "+err.Error(),
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, issuedKeyJSON{key, secret}, http.StatusCreated)
+	logging.LogError(err)
+}
+
+// GET /api-keys
+// lists every api key, revoked or not, or those of the tenant the admin
+// is bound to.
+func (a *API_V2) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := a.keys.List(r.Context(), boundTenant(r))
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, keys, http.StatusOK)
+	logging.LogError(err)
+}
+
+// DELETE /api-keys/{id}
+// revokes an api key.
+func (a *API_V2) DeleteAPIKeys(w http.ResponseWriter, r *http.Request) {
+	id := middleware.PathID(r)
+
+	key, err := a.keys.Revoke(r.Context(), id, boundTenant(r))
+	if errors.Is(err, apikey.ErrKeyDoesNotExist) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			fmt.Sprintf("api key of id %v does not exist", id),
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, key, http.StatusOK)
+	logging.LogError(err)
+}
+
+// boundTenant is the tenant the principal of a request is bound to, if
+// any.
+func boundTenant(r *http.Request) string {
+	principal, _ := auth.FromContext(r.Context())
+	return principal.Tenant
+}
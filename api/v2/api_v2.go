@@ -2,20 +2,112 @@ package v2
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/middleware"
 	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/webhook"
 )
 
 type API_V2 struct {
 	records service.RecordService
+	changes *service.ChangeFeed
+
+	// webhooks and keys are nil when the storage doesn't support them.
+	webhooks *webhook.Store
+	keys     *apikey.Store
+
+	// signer is nil when no attestation key is configured.
+	signer *attest.Signer
+
+	// router builds the links of JSON:API responses.
+	router *mux.Router
 }
 
-func NewV2API(records service.RecordService) *API_V2 {
-	return &API_V2{records}
+func NewV2API(records service.RecordService, changes *service.ChangeFeed, webhooks *webhook.Store, keys *apikey.Store, signer *attest.Signer) *API_V2 {
+	return &API_V2{records: records, changes: changes, webhooks: webhooks, keys: keys, signer: signer}
+}
+
+// Routes lists the routes of v2 along with their OpenAPI description.
+func (a *API_V2) Routes() []openapi.Route {
+	routes := []openapi.Route{
+		{
+			Method:      "GET",
+			Path:        "/records/{id}",
+			Handler:     a.GetRecords,
+			OperationID: "getRecordV2",
+			Summary:     "Get the latest version of a record, or its version at a given time (which also requires the history scope)",
+			Parameters: []openapi.Parameter{
+				openapi.IDParameter(),
+				openapi.AtParameter(),
+			},
+			Response: openapi.Ref("Record"),
+			JSONAPI:  true,
+			Scope:    auth.ScopeRead,
+		},
+		{
+			Method:      "POST",
+			Path:        "/records/{id}",
+			Handler:     a.PostRecords,
+			OperationID: "postRecordV2",
+			Summary:     "Create a record, or add a version on top of the latest one",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			RequestBody: openapi.RecordPayloadSchema(),
+			Response:    openapi.Ref("Record"),
+			JSONAPI:     true,
+			Scope:       auth.ScopeWrite,
+		},
+		{
+			Method:      "GET",
+			Path:        "/records/{id}/versions",
+			Handler:     a.GetVersions,
+			OperationID: "getVersionsV2",
+			Summary:     "List every version of a record, newest first",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			Response:    openapi.ArrayOf(openapi.Ref("Record")),
+			JSONAPI:     true,
+			Scope:       auth.ScopeHistory,
+		},
+		{
+			Method:      "GET",
+			Path:        "/records/{id}/verification",
+			Handler:     a.GetVerification,
+			OperationID: "verifyRecordV2",
+			Summary:     "Verify the hash chain of the versions of a record, reporting the first broken link",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			Response:    openapi.Ref("Verification"),
+			Scope:       auth.ScopeHistory,
+		},
+		{
+			Method:      "GET",
+			Path:        "/records/changes/stream",
+			Handler:     a.GetChangesStream,
+			OperationID: "streamChangesV2",
+			Summary:     "Stream every new version as server-sent events, resumable with Last-Event-ID",
+			Parameters:  []openapi.Parameter{openapi.IDQueryParameter()},
+			Response:    openapi.Ref("Record"),
+			ContentType: "text/event-stream",
+			Scope:       auth.ScopeHistory,
+		},
+	}
+
+	routes = append(routes, a.attestationRoutes()...)
+	routes = append(routes, a.erasureRoutes()...)
+	routes = append(routes, a.maintenanceRoutes()...)
+	routes = append(routes, a.webhookRoutes()...)
+	return append(routes, a.apiKeyRoutes()...)
 }
 
 func (a *API_V2) CreateRoutes(routes *mux.Router) {
-	routes.Path("/records/{id}").HandlerFunc(a.GetRecords).Methods("GET")
-	routes.Path("/records/{id}").HandlerFunc(a.PostRecords).Methods("POST")
-	routes.Path("/records/{id}/versions").HandlerFunc(a.GetVersions).
-		Methods("GET")
+	a.router = routes
+	routes.Use(middleware.JSONAPIMiddleware)
+
+	for _, route := range a.Routes() {
+		handler := middleware.ValidationMiddleware(route)(route.Handler)
+		handler = middleware.RequireScope(route.Scope)(handler)
+		routes.Path(route.Path).Handler(handler).Methods(route.Method).
+			Name(route.OperationID)
+	}
 }
@@ -0,0 +1,91 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// attestationRoutes lists the routes signing attestations of records,
+// which are only served when an attestation key is configured.
+func (a *API_V2) attestationRoutes() []openapi.Route {
+	if a.signer == nil {
+		return nil
+	}
+
+	return []openapi.Route{
+		{
+			Method:      "GET",
+			Path:        "/records/{id}/attestation",
+			Handler:     a.GetAttestation,
+			OperationID: "attestRecordV2",
+			Summary:     "Sign the version of a record at a given time, or its latest version, for offline verification",
+			Parameters: []openapi.Parameter{
+				openapi.IDParameter(),
+				openapi.AtParameter(),
+			},
+			Response: openapi.Ref("Attestation"),
+			Scope:    auth.ScopeRead,
+		},
+	}
+}
+
+// GET /records/{id}/attestation
+// signs the record as it was at a time, along with the hash of that
+// version and the fields left out of it, with the attestation key of the
+// server.
+func (a *API_V2) GetAttestation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := middleware.PathID(r)
+	at, hasAt := middleware.QueryTime(r, "at")
+
+	// looking into the past is reading the history
+	if hasAt && !middleware.Authorize(w, r, auth.ScopeHistory) {
+		return
+	}
+
+	var record model.Record
+	var err error
+	if hasAt {
+		record, err = a.records.GetRecordAt(ctx, id, at)
+	} else {
+		record, err = a.records.GetRecord(ctx, id)
+	}
+	if err != nil {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			fmt.Sprintf("record of id %v does not exist", id),
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+
+	omitted := auth.Unreadable(ctx)
+	recordJSON, err := record.ToJSON(omitted...)
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+	attestation, err := a.signer.Sign(attest.Statement{
+		Record:  recordJSON,
+		Omitted: omitted,
+		Hash:    record.Hash,
+		At:      at,
+	})
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, attestation, http.StatusOK)
+	logging.LogError(err)
+}
@@ -0,0 +1,102 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+// erasureRoutes lists the routes erasing the personal data of records.
+func (a *API_V2) erasureRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      "POST",
+			Path:        "/records/{id}/erasures",
+			Handler:     a.PostErasures,
+			OperationID: "eraseRecordV2",
+			Summary:     "Erase the personal data of every version of a record, keeping their timestamps",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			RequestBody: openapi.ErasurePayloadSchema(),
+			Response:    openapi.Ref("Erasure"),
+			Scope:       auth.ScopeAdmin,
+		},
+		{
+			Method:      "GET",
+			Path:        "/records/{id}/erasures",
+			Handler:     a.GetErasures,
+			OperationID: "listErasuresV2",
+			Summary:     "List the erasures of a record, oldest first",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			Response:    openapi.ArrayOf(openapi.Ref("Erasure")),
+			Scope:       auth.ScopeAdmin,
+		},
+	}
+}
+
+// POST /records/{id}/erasures
+// erases the mutable fields of every version of a record, and records
+// who erased them and why.
+func (a *API_V2) PostErasures(w http.ResponseWriter, r *http.Request) {
+	id := middleware.PathID(r)
+	reason, _ := middleware.Body(r)["reason"].(string)
+
+	if reason == "" {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			"invalid input; reason is required",
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+
+	erasure, err := a.records.EraseRecord(r.Context(), id, reason)
+	if errors.Is(err, service.ErrRecordDoesNotExist) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			fmt.Sprintf("record of id %v does not exist", id),
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+	if errors.Is(err, service.ErrErasureWithoutChainKey) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			"erasure is disabled; the server has no chain key",
+			http.StatusForbidden,
+		)
+		logging.LogError(err)
+		return
+	}
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, erasure, http.StatusCreated)
+	logging.LogError(err)
+}
+
+// GET /records/{id}/erasures
+// lists the erasures of a record.
+func (a *API_V2) GetErasures(w http.ResponseWriter, r *http.Request) {
+	erasures, err := a.records.GetErasures(r.Context(), middleware.PathID(r))
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, erasures, http.StatusOK)
+	logging.LogError(err)
+}
@@ -0,0 +1,100 @@
+package v2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+// keepAlive is how often an idle stream sends a comment, so proxies
+// don't close it.
+const keepAlive = 15 * time.Second
+
+// GET /records/changes/stream?id={id}
+// GetChangesStream pushes every new version, of a single record when id
+// is given, as a server-sent event whose id is its change sequence
+// number. Clients resume after a disconnect by sending the last id they
+// received as the Last-Event-ID header.
+func (a *API_V2) GetChangesStream(w http.ResponseWriter, r *http.Request) {
+	id, _ := middleware.QueryID(r)
+
+	var after uint64
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		var err error
+		after, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			err := response.WriteErrorFor(
+				w,
+				r,
+				"invalid Last-Event-ID; Last-Event-ID must be a change sequence number",
+				http.StatusBadRequest,
+			)
+			logging.LogError(err)
+			return
+		}
+	}
+
+	omit := auth.Unreadable(r.Context())
+	changes, cancel := a.changes.Subscribe(r.Context(), id, after)
+	defer cancel()
+
+	// streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logging.LogError(err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	logging.LogError(rc.Flush())
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case change, ok := <-changes:
+			if !ok {
+				// the client reconnects and resumes from its last event
+				return
+			}
+			err = writeChange(w, change, omit)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			logging.LogError(err)
+			return
+		}
+	}
+}
+
+func writeChange(w http.ResponseWriter, change service.Change, omit []string) error {
+	recordJSON, err := change.Record.ToJSON(omit...)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(recordJSON)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: record\ndata: %s\n\n", change.Sequence, data)
+	return err
+}
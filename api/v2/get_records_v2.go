@@ -3,64 +3,52 @@ package v2
 import (
 	"fmt"
 	"net/http"
-	"strconv"
-	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/concern/auth"
 	"github.com/rainbowmga/timetravel/concern/logging"
 	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/model"
 )
 
 // GET /records/{id}
 // GetRecord retrieves the record.
 func (a *API_V2) GetRecords(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	id := mux.Vars(r)["id"]
-	at := r.URL.Query().Get("at")
+	id := middleware.PathID(r)
+	at, hasAt := middleware.QueryTime(r, "at")
 
-	idNumber, err := strconv.ParseInt(id, 10, 32)
-
-	if err != nil || idNumber <= 0 {
-		err := response.WriteError(
-			w,
-			"invalid id; id must be a positive number",
-			http.StatusBadRequest,
-		)
-		logging.LogError(err)
+	// looking into the past is reading the history
+	if hasAt && !middleware.Authorize(w, r, auth.ScopeHistory) {
 		return
 	}
 
-	atTime := time.Now()
-	if at != "" {
-		parsedTime, err := time.Parse(time.RFC3339, at)
-		if err != nil {
-			err := response.WriteError(
-				w,
-				"invalid time; time must be in RFC3339 format",
-				http.StatusBadRequest,
-			)
-			logging.LogError(err)
-			return
-		} else {
-			atTime = parsedTime
-		}
+	// without `at` the service's clock decides what the latest version is
+	var record model.Record
+	var err error
+	if hasAt {
+		record, err = a.records.GetRecordAt(
+			ctx,
+			id,
+			at,
+		)
+	} else {
+		record, err = a.records.GetRecord(
+			ctx,
+			id,
+		)
 	}
 
-	record, err := a.records.GetRecordAt(
-		ctx,
-		uint(idNumber),
-		atTime,
-	)
-
 	if err != nil {
-		err := response.WriteError(
+		err := response.WriteErrorFor(
 			w,
-			fmt.Sprintf("record of id %v does not exist", idNumber),
+			r,
+			fmt.Sprintf("record of id %v does not exist", id),
 			http.StatusBadRequest,
 		)
 		logging.LogError(err)
 		return
 	}
 
-	response.WriteRecord(w, record)
+	a.writeRecord(w, r, record)
 }
@@ -0,0 +1,38 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+// GET /records/{id}/verification
+// GetVerification walks the hash chain of the versions of a record, and
+// reports the first version whose link is broken, if any.
+func (a *API_V2) GetVerification(w http.ResponseWriter, r *http.Request) {
+	id := middleware.PathID(r)
+
+	report, err := a.records.VerifyRecord(r.Context(), id)
+	if errors.Is(err, service.ErrRecordDoesNotExist) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			fmt.Sprintf("record of id %v does not exist", id),
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, report, http.StatusOK)
+	logging.LogError(err)
+}
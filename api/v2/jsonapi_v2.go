@@ -0,0 +1,191 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// jsonAPIResource is a version of a record as a JSON:API resource
+// object, identified by the record id and the time the version was
+// updated at, e.g. 30@2024-08-25T23:13:02Z. The mutable fields are its
+// attributes, while its version number and timestamps are meta.
+type jsonAPIResource struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Meta       jsonAPIMeta            `json:"meta"`
+	Links      jsonAPILinks           `json:"links"`
+}
+
+// jsonAPIMeta leaves out the number of the version, which is part of
+// the history of the record, unless the caller can read it.
+type jsonAPIMeta struct {
+	Version   int        `json:"version,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Actor     string     `json:"actor"`
+	Hash      string     `json:"hash"`
+	ErasedAt  *time.Time `json:"erased_at,omitempty"`
+}
+
+// jsonAPILinks point to the version itself and, when the caller can
+// read the history of the record, to its history.
+type jsonAPILinks struct {
+	Self string `json:"self"`
+	*jsonAPIHistoryLinks
+}
+
+// jsonAPIHistoryLinks point to the list of versions, and the
+// neighbouring versions (null for the first and latest version).
+type jsonAPIHistoryLinks struct {
+	Versions string  `json:"versions"`
+	Previous *string `json:"previous"`
+	Next     *string `json:"next"`
+}
+
+type jsonAPIDocument struct {
+	Data    interface{}       `json:"data"`
+	Links   map[string]string `json:"links"`
+	JSONAPI map[string]string `json:"jsonapi"`
+}
+
+// writeRecord writes a record in the format negotiated by the request.
+func (a *API_V2) writeRecord(w http.ResponseWriter, r *http.Request, record model.Record) {
+	if !response.IsJSONAPI(r) {
+		response.WriteRecord(w, r, record)
+		return
+	}
+
+	// the neighbours of the version are needed for its number and links,
+	// which are only given to callers who can read the history
+	omit := auth.Unreadable(r.Context())
+	principal, _ := auth.FromContext(r.Context())
+	if !principal.Can(auth.ScopeHistory) {
+		a.writeJSONAPI(w, r, a.jsonAPIResource([]model.Record{record}, 0, omit, false))
+		return
+	}
+	versions, err := a.records.GetVersions(r.Context(), record.ID)
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	for i, version := range versions {
+		if version.UpdatedAt.Equal(record.UpdatedAt) {
+			a.writeJSONAPI(w, r, a.jsonAPIResource(versions, i, omit, true))
+			return
+		}
+	}
+
+	a.writeInternalError(w, r, fmt.Errorf("version of record %d not found", record.ID))
+}
+
+// writeVersions writes all versions of a record, newest first, in the
+// format negotiated by the request.
+func (a *API_V2) writeVersions(w http.ResponseWriter, r *http.Request, versions []model.Record) {
+	if !response.IsJSONAPI(r) {
+		response.WriteRecords(w, r, versions)
+		return
+	}
+
+	omit := auth.Unreadable(r.Context())
+	resources := make([]jsonAPIResource, len(versions))
+	for i := range versions {
+		resources[i] = a.jsonAPIResource(versions, i, omit, true)
+	}
+	a.writeJSONAPI(w, r, resources)
+}
+
+// jsonAPIResource converts versions[i] where versions are newest first,
+// leaving out the omitted attributes, and the number and links of the
+// version unless history is set.
+func (a *API_V2) jsonAPIResource(versions []model.Record, i int, omit []string, history bool) jsonAPIResource {
+	record := versions[i]
+
+	attributes := record.GetData()
+	if record.ErasedAt != nil {
+		for field := range attributes {
+			attributes[field] = model.Redacted
+		}
+	}
+	for _, field := range omit {
+		delete(attributes, field)
+	}
+	resource := jsonAPIResource{
+		Type:       "records",
+		ID:         fmt.Sprintf("%d@%s", record.ID, record.UpdatedAt.UTC().Format(time.RFC3339)),
+		Attributes: attributes,
+		Meta: jsonAPIMeta{
+			CreatedAt: record.CreatedAt,
+			UpdatedAt: record.UpdatedAt,
+			Actor:     record.Actor,
+			Hash:      record.Hash,
+			ErasedAt:  record.ErasedAt,
+		},
+		Links: jsonAPILinks{Self: a.versionURL(record)},
+	}
+	if !history {
+		return resource
+	}
+
+	resource.Meta.Version = len(versions) - i
+	links := &jsonAPIHistoryLinks{Versions: a.url("getVersionsV2", record.ID)}
+	if i+1 < len(versions) {
+		previous := a.versionURL(versions[i+1])
+		links.Previous = &previous
+	}
+	if i > 0 {
+		next := a.versionURL(versions[i-1])
+		links.Next = &next
+	}
+	resource.Links.jsonAPIHistoryLinks = links
+	return resource
+}
+
+// versionURL is the permanent url of a version of a record.
+func (a *API_V2) versionURL(record model.Record) string {
+	at := record.UpdatedAt.Format(time.RFC3339)
+	return a.url("getRecordV2", record.ID) +
+		"?at=" + strings.ReplaceAll(at, "+", "%2B")
+}
+
+// url builds the path of a named route for a record id.
+func (a *API_V2) url(name string, id uint) string {
+	url, err := a.router.Get(name).URL("id", fmt.Sprint(id))
+	if err != nil {
+		logging.LogError(err)
+		return ""
+	}
+	return url.Path
+}
+
+func (a *API_V2) writeJSONAPI(w http.ResponseWriter, r *http.Request, data interface{}) {
+	err := response.WriteJSONAPI(
+		w,
+		jsonAPIDocument{
+			Data:    data,
+			Links:   map[string]string{"self": r.URL.RequestURI()},
+			JSONAPI: map[string]string{"version": "1.0"},
+		},
+		http.StatusOK,
+	)
+	logging.LogError(err)
+}
+
+func (a *API_V2) writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.LogError(err)
+	err = response.WriteErrorFor(
+		w,
+		r,
+		response.ErrInternal.Error(),
+		http.StatusInternalServerError,
+	)
+	logging.LogError(err)
+}
@@ -0,0 +1,108 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+// maintenanceRoutes lists the routes changing existing versions of
+// records, which are otherwise append-only, and auditing those changes.
+func (a *API_V2) maintenanceRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      "POST",
+			Path:        "/records/{id}/corrections",
+			Handler:     a.PostCorrections,
+			OperationID: "correctVersionV2",
+			Summary:     "Correct fields of a version of a record in place, chaining the later versions to it again",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			RequestBody: openapi.CorrectionPayloadSchema(),
+			Response:    openapi.Ref("Maintenance"),
+			Scope:       auth.ScopeAdmin,
+		},
+		{
+			Method:      "GET",
+			Path:        "/records/{id}/maintenance",
+			Handler:     a.GetMaintenance,
+			OperationID: "listMaintenanceV2",
+			Summary:     "List the changes to existing versions of a record, oldest first",
+			Parameters:  []openapi.Parameter{openapi.IDParameter()},
+			Response:    openapi.ArrayOf(openapi.Ref("Maintenance")),
+			Scope:       auth.ScopeAdmin,
+		},
+	}
+}
+
+// POST /records/{id}/corrections
+// replaces fields of the version of a record updated at `updated_at`,
+// and records who corrected it and why.
+func (a *API_V2) PostCorrections(w http.ResponseWriter, r *http.Request) {
+	id := middleware.PathID(r)
+	body := middleware.Body(r)
+	reason, _ := body["reason"].(string)
+	data, _ := body["data"].(map[string]interface{})
+	at, _ := time.Parse(time.RFC3339, body["updated_at"].(string))
+
+	if reason == "" {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			"invalid input; reason is required",
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+
+	entry, err := a.records.CorrectVersion(r.Context(), id, at, data, reason)
+	var message string
+	switch {
+	case errors.Is(err, service.ErrRecordDoesNotExist):
+		message = fmt.Sprintf("record of id %v does not exist", id)
+	case errors.Is(err, service.ErrVersionDoesNotExist):
+		message = fmt.Sprintf("record of id %v has no version updated at %s", id, at.Format(time.RFC3339))
+	case errors.Is(err, service.ErrVersionErased):
+		message = fmt.Sprintf("version of record %v updated at %s was erased", id, at.Format(time.RFC3339))
+	case errors.Is(err, service.ErrCorrectionEmpty):
+		message = "invalid input; data has no fields to correct"
+	}
+	if message != "" {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			message,
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, entry, http.StatusCreated)
+	logging.LogError(err)
+}
+
+// GET /records/{id}/maintenance
+// lists the corrections and erasures of a record.
+func (a *API_V2) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	entries, err := a.records.GetMaintenance(r.Context(), middleware.PathID(r))
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, entries, http.StatusOK)
+	logging.LogError(err)
+}
@@ -1,15 +1,14 @@
 package v2
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
-	"strconv"
 
-	"github.com/gorilla/mux"
 	"github.com/rainbowmga/timetravel/concern/logging"
 	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/service"
 	"github.com/rs/zerolog/log"
-	"gorm.io/gorm"
 )
 
 // POST /records/{id}
@@ -17,67 +16,59 @@ import (
 // if the record doesn't exist, the record is created.
 func (a *API_V2) PostRecords(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	id := mux.Vars(r)["id"]
-	idNumber, err := strconv.ParseInt(id, 10, 32)
+	id := middleware.PathID(r)
+	body := middleware.Body(r)
 
-	if err != nil || idNumber <= 0 {
-		err := response.WriteError(
-			w,
-			"invalid id; id must be a positive number",
-			http.StatusBadRequest,
-		)
-		logging.LogError(err)
-		return
-	}
-
-	var body map[string]interface{}
-	err = json.NewDecoder(r.Body).Decode(&body)
-
-	if err != nil {
-		err := response.WriteError(
-			w,
-			"invalid input; could not parse json",
-			http.StatusBadRequest,
-		)
-		logging.LogError(err)
+	if !middleware.AuthorizeFields(w, r, body) {
 		return
 	}
 
 	// first retrieve the record
 	record, err := a.records.GetRecord(
 		ctx,
-		uint(idNumber),
+		id,
 	)
 
 	if err == nil {
 		log.Info().Msg("Update Existing Record")
 		record, err = a.records.UpdateRecord(ctx, record, body)
 		if err == nil {
-			response.WriteRecord(w, record)
+			a.writeRecord(w, r, record)
 		} else {
-			err := response.WriteError(
+			err := response.WriteErrorFor(
 				w,
+				r,
 				response.ErrInternal.Error(),
 				http.StatusInternalServerError,
 			)
 			logging.LogError(err)
 		}
-	} else if err == gorm.ErrRecordNotFound {
+	} else if errors.Is(err, service.ErrRecordDoesNotExist) {
 		log.Info().Msg("Create New Record")
-		record, err = a.records.CreateRecord(ctx, uint(idNumber), body)
+		record, err = a.records.CreateRecord(ctx, id, body)
 		if err == nil {
-			response.WriteRecord(w, record)
+			a.writeRecord(w, r, record)
+		} else if errors.Is(err, service.ErrTenantDoesNotExist) {
+			err := response.WriteErrorFor(
+				w,
+				r,
+				err.Error(),
+				http.StatusBadRequest,
+			)
+			logging.LogError(err)
 		} else {
-			err := response.WriteError(
+			err := response.WriteErrorFor(
 				w,
+				r,
 				response.ErrInternal.Error(),
 				http.StatusInternalServerError,
 			)
 			logging.LogError(err)
 		}
 	} else {
-		err := response.WriteError(
+		err := response.WriteErrorFor(
 			w,
+			r,
 			response.ErrInternal.Error(),
 			http.StatusInternalServerError,
 		)
@@ -0,0 +1,163 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/webhook"
+)
+
+// webhookRoutes lists the routes managing webhooks, which are only
+// served when the storage supports them.
+func (a *API_V2) webhookRoutes() []openapi.Route {
+	if a.webhooks == nil {
+		return nil
+	}
+
+	return []openapi.Route{
+		{
+			Method:      "POST",
+			Path:        "/webhooks",
+			Handler:     a.PostWebhooks,
+			OperationID: "createWebhookV2",
+			Summary:     "Subscribe a url to the new versions of records",
+			RequestBody: openapi.WebhookPayloadSchema(),
+			Response:    openapi.Ref("Webhook"),
+			Scope:       auth.ScopeAdmin,
+		},
+		{
+			Method:      "GET",
+			Path:        "/webhooks",
+			Handler:     a.GetWebhooks,
+			OperationID: "listWebhooksV2",
+			Summary:     "List the webhook subscriptions",
+			Response:    openapi.ArrayOf(openapi.Ref("Webhook")),
+			Scope:       auth.ScopeAdmin,
+		},
+		{
+			Method:      "DELETE",
+			Path:        "/webhooks/{id}",
+			Handler:     a.DeleteWebhooks,
+			OperationID: "deleteWebhookV2",
+			Summary:     "Unsubscribe a url, dropping its pending deliveries",
+			Parameters:  []openapi.Parameter{openapi.WebhookIDParameter()},
+			Scope:       auth.ScopeAdmin,
+		},
+		{
+			Method:      "GET",
+			Path:        "/webhooks/dead-letters",
+			Handler:     a.GetDeadLetters,
+			OperationID: "listDeadLettersV2",
+			Summary:     "List the webhook deliveries that ran out of attempts, newest first",
+			Response:    openapi.ArrayOf(openapi.Ref("DeadLetter")),
+			Scope:       auth.ScopeAdmin,
+		},
+	}
+}
+
+// POST /webhooks
+// subscribes a url to the new versions of every record, or only of
+// those changing one of the fields.
+func (a *API_V2) PostWebhooks(w http.ResponseWriter, r *http.Request) {
+	body := middleware.Body(r)
+
+	url, _ := body["url"].(string)
+	secret, _ := body["secret"].(string)
+	fields := []string{}
+	if list, ok := body["fields"].([]interface{}); ok {
+		for _, field := range list {
+			fields = append(fields, field.(string))
+		}
+	}
+
+	subscription, err := a.webhooks.CreateSubscription(r.Context(), url, secret, fields)
+	if errors.Is(err, webhook.ErrInvalidURL) || errors.Is(err, webhook.ErrInvalidField) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			"invalid input; "+err.Error(),
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, subscription, http.StatusCreated)
+	logging.LogError(err)
+}
+
+// GET /webhooks
+// lists the webhook subscriptions, without their secrets.
+func (a *API_V2) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := a.webhooks.ListSubscriptions(r.Context())
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	err = response.WriteJSON(w, subscriptions, http.StatusOK)
+	logging.LogError(err)
+}
+
+// DELETE /webhooks/{id}
+// unsubscribes a url.
+func (a *API_V2) DeleteWebhooks(w http.ResponseWriter, r *http.Request) {
+	id := middleware.PathID(r)
+
+	err := a.webhooks.DeleteSubscription(r.Context(), id)
+	if errors.Is(err, webhook.ErrSubscriptionDoesNotExist) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			fmt.Sprintf("webhook of id %v does not exist", id),
+			http.StatusBadRequest,
+		)
+		logging.LogError(err)
+		return
+	}
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type deadLetterJSON struct {
+	webhook.Delivery
+	Payload webhook.Payload `json:"payload"`
+}
+
+// GET /webhooks/dead-letters
+// lists the deliveries that ran out of attempts, with their payload as
+// it would be sent now.
+func (a *API_V2) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := a.webhooks.ListDeadLetters(r.Context())
+	if err != nil {
+		a.writeInternalError(w, r, err)
+		return
+	}
+
+	deadLetters := make([]deadLetterJSON, len(deliveries))
+	for i, delivery := range deliveries {
+		payload, err := a.webhooks.Payload(r.Context(), delivery)
+		if err != nil {
+			a.writeInternalError(w, r, err)
+			return
+		}
+		deadLetters[i] = deadLetterJSON{delivery, payload}
+	}
+
+	err = response.WriteJSON(w, deadLetters, http.StatusOK)
+	logging.LogError(err)
+}
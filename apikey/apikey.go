@@ -0,0 +1,171 @@
+// Package apikey issues and authenticates api keys.
+//
+// Only the sha256 of a key is stored: keys are random enough that a
+// slow hash would add nothing, and it lets keys be looked up directly.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/model"
+	"gorm.io/gorm"
+)
+
+var ErrKeyDoesNotExist = errors.New("api key with that id does not exist")
+var ErrInvalidScope = errors.New("scopes must be read, write, history or admin")
+var ErrNameRequired = errors.New("name is required")
+var ErrInvalidTenant = errors.New("tenant must be lowercase letters, digits, - or _")
+var ErrInvalidRole = errors.New("roles must not be empty nor contain commas")
+
+// keyPrefix starts every key, so leaked keys are easy to spot.
+const keyPrefix = "tt_"
+
+type Key struct {
+	ID     uint             `json:"id"`
+	Name   string           `json:"name"`
+	Prefix string           `json:"prefix"`
+	Hash   string           `json:"-"`
+	Scopes model.StringList `gorm:"type:text" json:"scopes"`
+
+	// Roles give the key access to the fields of records of the roles,
+	// see auth.FieldRoles. Keys without any are not restricted.
+	Roles model.StringList `gorm:"type:text" json:"roles"`
+
+	// Tenant binds the key to the records of a tenant, when set.
+	Tenant string `json:"tenant"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+func (Key) TableName() string {
+	return "api_keys"
+}
+
+// Principal is the caller presenting the key.
+func (k Key) Principal() auth.Principal {
+	return auth.Principal{Subject: "apikey:" + k.Name, Scopes: k.Scopes, Roles: k.Roles, Tenant: k.Tenant}
+}
+
+// Store manages the api keys, and authenticates them.
+type Store struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+func NewStore(db *gorm.DB, clock clock.Clock) Store {
+	return Store{db, clock}
+}
+
+// Issue creates a key with scopes and roles, bound to tenant unless it
+// is empty, returning it along with the secret presented by clients,
+// which can't be retrieved later.
+func (s *Store) Issue(ctx context.Context, name string, scopes []string, roles []string, tenantName string) (Key, string, error) {
+	if name == "" {
+		return Key{}, "", ErrNameRequired
+	}
+	if tenantName != "" && !tenant.IsValid(tenantName) {
+		return Key{}, "", ErrInvalidTenant
+	}
+	if len(scopes) == 0 {
+		return Key{}, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !auth.IsScope(scope) {
+			return Key{}, "", ErrInvalidScope
+		}
+	}
+	for _, role := range roles {
+		if role == "" || strings.Contains(role, ",") {
+			return Key{}, "", ErrInvalidRole
+		}
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return Key{}, "", err
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	key := Key{
+		Name:      name,
+		Prefix:    secret[:len(keyPrefix)+6],
+		Hash:      hash(secret),
+		Scopes:    append(model.StringList{}, scopes...),
+		Roles:     append(model.StringList{}, roles...),
+		Tenant:    tenantName,
+		CreatedAt: s.clock.Now().UTC(),
+	}
+	result := s.db.WithContext(ctx).Create(&key)
+	if result.Error != nil {
+		return Key{}, "", result.Error
+	}
+	return key, secret, nil
+}
+
+// List returns the keys bound to tenant, or every key when it is empty,
+// revoked or not.
+func (s *Store) List(ctx context.Context, tenant string) ([]Key, error) {
+	keys := []Key{}
+	result := ofTenant(s.db.WithContext(ctx), tenant).Order("id").Find(&keys)
+	return keys, result.Error
+}
+
+// Revoke stops a key bound to tenant, or any key when it is empty, from
+// authenticating. It is kept for the audit trail.
+func (s *Store) Revoke(ctx context.Context, id uint, tenant string) (Key, error) {
+	db := s.db.WithContext(ctx)
+
+	var key Key
+	result := ofTenant(db, tenant).First(&key, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return Key{}, ErrKeyDoesNotExist
+	}
+	if result.Error != nil {
+		return Key{}, result.Error
+	}
+
+	if key.RevokedAt == nil {
+		now := s.clock.Now().UTC()
+		key.RevokedAt = &now
+		result = db.Model(&key).Update("revoked_at", now)
+	}
+	return key, result.Error
+}
+
+// Authenticate implements auth.Authenticator for the secret of a key.
+func (s *Store) Authenticate(ctx context.Context, secret string) (auth.Principal, error) {
+	var key Key
+	result := s.db.WithContext(ctx).
+		Where("hash = ? AND revoked_at IS NULL", hash(secret)).
+		First(&key)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	if result.Error != nil {
+		return auth.Principal{}, result.Error
+	}
+	return key.Principal(), nil
+}
+
+func ofTenant(db *gorm.DB, tenant string) *gorm.DB {
+	if tenant == "" {
+		return db
+	}
+	return db.Where("tenant = ?", tenant)
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
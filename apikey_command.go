@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rs/zerolog/log"
+)
+
+const apiKeyUsage = "usage: timetravel apikey issue [--roles=<role>,...] <name> <scope>[,<scope>...] [<tenant>]|list|revoke <id>"
+
+// runAPIKey implements `timetravel apikey issue|list|revoke`, which
+// bootstraps the first admin key.
+func runAPIKey(keys *apikey.Store, args []string) {
+	ctx := context.Background()
+	if len(args) == 0 {
+		log.Fatal().Msg(apiKeyUsage)
+	}
+
+	var roles []string
+	if args[0] == "issue" {
+		roles, args = issueFlags(args)
+	}
+
+	switch {
+	case args[0] == "issue" && (len(args) == 3 || len(args) == 4):
+		tenant := ""
+		if len(args) == 4 {
+			tenant = args[3]
+		}
+		key, secret, err := keys.Issue(ctx, args[1], strings.Split(args[2], ","), roles, tenant)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not issue the api key")
+		}
+		log.Info().Msgf("issued api key %d %q with scopes %s and roles %s",
+			key.ID, key.Name, strings.Join(key.Scopes, ","), strings.Join(key.Roles, ","))
+		fmt.Println(secret)
+	case args[0] == "list" && len(args) == 1:
+		list, err := keys.List(ctx, "")
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not list the api keys")
+		}
+		for _, key := range list {
+			status := "active"
+			if key.RevokedAt != nil {
+				status = "revoked " + key.RevokedAt.Format(time.RFC3339)
+			}
+			tenant := key.Tenant
+			if tenant == "" {
+				tenant = "*"
+			}
+			roles := strings.Join(key.Roles, ",")
+			if roles == "" {
+				roles = "-"
+			}
+			fmt.Printf("%d  %s  %s  %s  %s  %s  %s\n", key.ID, key.Prefix, key.Name, strings.Join(key.Scopes, ","), roles, tenant, status)
+		}
+	case args[0] == "revoke" && len(args) == 2:
+		id, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			log.Fatal().Msg(apiKeyUsage)
+		}
+		key, err := keys.Revoke(ctx, uint(id), "")
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not revoke the api key")
+		}
+		log.Info().Msgf("revoked api key %d %q", key.ID, key.Name)
+	default:
+		log.Fatal().Msg(apiKeyUsage)
+	}
+}
+
+// issueFlags parses the flags of `apikey issue`, returning the roles
+// of the key and the arguments without the flags.
+func issueFlags(args []string) ([]string, []string) {
+	fs := flag.NewFlagSet("apikey issue", flag.ContinueOnError)
+	list := fs.String("roles", "", "roles of the key, comma separated")
+	if err := fs.Parse(args[1:]); err != nil {
+		log.Fatal().Msg(apiKeyUsage)
+	}
+
+	var roles []string
+	if *list != "" {
+		roles = strings.Split(*list, ",")
+	}
+	return roles, append([]string{"issue"}, fs.Args()...)
+}
@@ -0,0 +1,223 @@
+// Package attest signs attestations of the state of records at a time,
+// which their recipients can verify offline with the public key of the
+// server, without access to the api or the database.
+package attest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/model"
+)
+
+var ErrInvalidKey = errors.New("the key is not a pem encoded ed25519 key")
+var ErrInvalidSignature = errors.New("invalid signature")
+var ErrUnknownKey = errors.New("the attestation was signed by another key")
+
+// Statement is the signed content of an attestation: the record as it
+// was at a time, and the hash of that version, see chain.
+//
+// Omitted lists the fields of the version left out of Record, e.g.
+// because the caller can't read them, which its hash still covers.
+type Statement struct {
+	Record   model.RecordJSON `json:"record"`
+	Omitted  []string         `json:"omitted"`
+	Hash     string           `json:"hash"`
+	At       time.Time        `json:"at"`
+	IssuedAt time.Time        `json:"issued_at"`
+	KeyID    string           `json:"key_id"`
+}
+
+// Attestation is a statement along with its Ed25519 signature. The
+// signature covers the canonical json encoding of the statement, see
+// canonical, so reformatting the attestation, or encoding it again with
+// another json library, doesn't invalidate it.
+type Attestation struct {
+	Statement json.RawMessage `json:"statement"`
+	Signature string          `json:"signature"`
+}
+
+// Signer signs statements with the private key of a key file.
+type Signer struct {
+	key   ed25519.PrivateKey
+	clock clock.Clock
+}
+
+// LoadSigner reads the pem encoded private key at path, which dates the
+// statements it signs with clock.
+func LoadSigner(path string, clock clock.Clock) (*Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrInvalidKey)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, ErrInvalidKey)
+	}
+	return &Signer{key, clock}, nil
+}
+
+// PublicKey returns the key verifying the attestations of the signer.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign issues an attestation of statement, which is issued now by the
+// key of the signer. A statement without a time is about the time it is
+// issued.
+func (s *Signer) Sign(statement Statement) (Attestation, error) {
+	statement.IssuedAt = s.clock.Now().Truncate(time.Second)
+	if statement.At.IsZero() {
+		statement.At = statement.IssuedAt
+	}
+	statement.KeyID = KeyID(s.PublicKey())
+	if statement.Omitted == nil {
+		statement.Omitted = []string{}
+	}
+
+	encoded, err := json.Marshal(statement)
+	if err != nil {
+		return Attestation{}, err
+	}
+	content, err := canonical(encoded)
+	if err != nil {
+		return Attestation{}, err
+	}
+	signature := ed25519.Sign(s.key, content)
+	return Attestation{content, base64.StdEncoding.EncodeToString(signature)}, nil
+}
+
+// Verify checks the signature of an attestation with the public key,
+// returning its statement.
+func Verify(attestation Attestation, key ed25519.PublicKey) (Statement, error) {
+	content, err := canonical(attestation.Statement)
+	if err != nil {
+		return Statement{}, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(attestation.Signature)
+	if err != nil {
+		return Statement{}, ErrInvalidSignature
+	}
+
+	var statement Statement
+	err = json.Unmarshal(content, &statement)
+	if err != nil {
+		return Statement{}, err
+	}
+	if statement.KeyID != KeyID(key) {
+		return Statement{}, fmt.Errorf("%w %q", ErrUnknownKey, statement.KeyID)
+	}
+	if !ed25519.Verify(key, content, signature) {
+		return Statement{}, ErrInvalidSignature
+	}
+	return statement, nil
+}
+
+// canonical encodes the json of content compactly, with the keys of
+// objects sorted, numbers as they are and html characters such as & not
+// escaped, unlike json.Marshal does by default, so that every encoding of
+// the same statement has the same canonical encoding.
+func canonical(content []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(encoded.Bytes(), []byte("\n")), nil
+}
+
+// KeyID identifies a public key by the start of its sha256 fingerprint.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateKey writes a new private key to path, which must not exist,
+// and returns the pem encoding of its public key.
+func GenerateKey(path string) ([]byte, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	err = pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return EncodePublicKey(public)
+}
+
+// EncodePublicKey encodes a public key in pem.
+func EncodePublicKey(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// LoadPublicKey reads the pem encoded public key at path.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrInvalidKey)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, ErrInvalidKey)
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrInvalidKey)
+	}
+	return block, nil
+}
@@ -0,0 +1,154 @@
+package attest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/model"
+)
+
+var now = time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+
+func newSigner(t *testing.T) *attest.Signer {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "attestation.pem")
+	if _, err := attest.GenerateKey(path); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := attest.LoadSigner(path, clock.NewFake(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestSignAndVerify(t *testing.T) {
+	signer := newSigner(t)
+	at := now.Add(-time.Hour)
+	attestation, err := signer.Sign(attest.Statement{
+		Record:  model.RecordJSON{ID: 30, Data: map[string]interface{}{"first_name": "Steve"}},
+		Omitted: []string{"email"},
+		Hash:    "866fe5d9",
+		At:      at,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement, err := attest.Verify(attestation, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if statement.Record.ID != 30 || statement.Hash != "866fe5d9" || !statement.At.Equal(at) ||
+		!statement.IssuedAt.Equal(now) || statement.KeyID != attest.KeyID(signer.PublicKey()) {
+		t.Errorf("statement = %+v, want the record at %v issued at %v", statement, at, now)
+	}
+	if len(statement.Omitted) != 1 || statement.Omitted[0] != "email" {
+		t.Errorf("omitted = %v, want [email]", statement.Omitted)
+	}
+
+	// the attestation survives being reformatted
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, attestation.Statement, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	_, err = attest.Verify(attest.Attestation{Statement: indented.Bytes(), Signature: attestation.Signature}, signer.PublicKey())
+	if err != nil {
+		t.Errorf("Verify indented: %v", err)
+	}
+
+	tampered := attestation
+	tampered.Statement = []byte(strings.Replace(string(attestation.Statement), "Steve", "Steven", 1))
+	_, err = attest.Verify(tampered, signer.PublicKey())
+	if !errors.Is(err, attest.ErrInvalidSignature) {
+		t.Errorf("Verify tampered err = %v, want ErrInvalidSignature", err)
+	}
+
+	_, err = attest.Verify(attestation, newSigner(t).PublicKey())
+	if !errors.Is(err, attest.ErrUnknownKey) {
+		t.Errorf("Verify with another key err = %v, want ErrUnknownKey", err)
+	}
+}
+
+// TestSignHTMLCharacters signs a field with html characters, which
+// json.Marshal escapes and other encoders don't.
+func TestSignHTMLCharacters(t *testing.T) {
+	signer := newSigner(t)
+	attestation, err := signer.Sign(attest.Statement{
+		Record: model.RecordJSON{ID: 30, Data: map[string]interface{}{"last_name": "Procter & <Gamble>"}},
+		Hash:   "866fe5d9",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(attestation.Statement), "Procter & <Gamble>") {
+		t.Errorf("statement = %s, want the html characters as they are", attestation.Statement)
+	}
+
+	// as served by the api, whose encoder escapes them
+	served, err := json.Marshal(attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(served), `\u0026`) {
+		t.Fatalf("served = %s, want & escaped", served)
+	}
+
+	for name, content := range map[string]string{
+		"Served":    string(served),
+		"Unescaped": strings.NewReplacer(`\u0026`, "&", `\u003c`, "<", `\u003e`, ">").Replace(string(served)),
+	} {
+		var received attest.Attestation
+		if err := json.Unmarshal([]byte(content), &received); err != nil {
+			t.Fatal(err)
+		}
+		statement, err := attest.Verify(received, signer.PublicKey())
+		if err != nil {
+			t.Errorf("Verify %s: %v", name, err)
+			continue
+		}
+		if statement.Record.Data["last_name"] != "Procter & <Gamble>" {
+			t.Errorf("Verify %s = %+v, want the html characters", name, statement)
+		}
+	}
+}
+
+func TestKeyFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attestation.pem")
+	public, err := attest.GenerateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attest.GenerateKey(path); err == nil {
+		t.Error("GenerateKey overwrote the existing key")
+	}
+
+	publicPath := filepath.Join(t.TempDir(), "attestation.pub")
+	if err := os.WriteFile(publicPath, public, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	key, err := attest.LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := attest.LoadSigner(path, clock.NewFake(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(signer.PublicKey()) {
+		t.Error("the public key doesn't match the signer")
+	}
+
+	_, err = attest.LoadSigner(publicPath, clock.NewFake(now))
+	if !errors.Is(err, attest.ErrInvalidKey) {
+		t.Errorf("LoadSigner with a public key err = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/concern/config"
+	"github.com/rs/zerolog/log"
+)
+
+const attestationUsage = "usage: timetravel --attestation-key-file <file> attestation keygen\n" +
+	"       timetravel attestation verify <public-key-file> <attestation-file|->"
+
+// runAttestation implements `timetravel attestation keygen`, which
+// creates the attestation key and prints its public key, and
+// `timetravel attestation verify`, which checks an attestation offline
+// with the public key.
+func runAttestation(c config.Config, args []string) {
+	switch {
+	case len(args) == 1 && args[0] == "keygen" && c.AttestationKeyFile != "":
+		public, err := attest.GenerateKey(c.AttestationKeyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not create the attestation key")
+		}
+		log.Info().Msgf("created the attestation key %s; its public key verifies the attestations:", c.AttestationKeyFile)
+		fmt.Print(string(public))
+	case len(args) == 3 && args[0] == "verify":
+		key, err := attest.LoadPublicKey(args[1])
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load the public key")
+		}
+		attestation, err := readAttestation(args[2])
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not read the attestation")
+		}
+
+		statement, err := attest.Verify(attestation, key)
+		if err != nil {
+			log.Fatal().Err(err).Msg("the attestation is not valid")
+		}
+		log.Info().Msgf("valid attestation of record %d at %s, version %s, issued at %s",
+			statement.Record.ID, statement.At.Format(time.RFC3339), statement.Hash,
+			statement.IssuedAt.Format(time.RFC3339))
+	default:
+		log.Fatal().Msg(attestationUsage)
+	}
+}
+
+// readAttestation reads an attestation from path, or stdin for -.
+func readAttestation(path string) (attest.Attestation, error) {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return attest.Attestation{}, err
+	}
+
+	var attestation attest.Attestation
+	err = json.Unmarshal(content, &attestation)
+	return attestation, err
+}
@@ -0,0 +1,136 @@
+// Package chain makes the history of records tamper-evident: every
+// version stores the digest of its content, and a hash chaining that
+// digest to the hash of the previous version of the record, so editing,
+// inserting or deleting a version in the database breaks the chain from
+// there on.
+//
+// Links are keyed with a secret kept outside of the database, see Key,
+// so that those who can edit it can't recompute the chain after editing
+// a version. Deleting the latest versions of a record, or a whole record,
+// leaves a valid chain behind, which checkpoints of the heads of the
+// chains kept outside of the database detect, see Checkpoint.
+//
+// The chain covers the digests rather than the content itself, so that
+// erasing the personal data of versions keeps their chain intact. The
+// digests are keyed too, or the digest an erased version keeps would
+// confirm guesses of its content.
+package chain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// Report is the result of the verification of the versions of a record.
+// Versions written before they were chained, which have no hash, are
+// only counted as unchained.
+type Report struct {
+	RecordID  uint   `json:"record_id"`
+	Versions  int    `json:"versions"`
+	Unchained int    `json:"unchained"`
+	Valid     bool   `json:"valid"`
+	Broken    *Break `json:"broken,omitempty"`
+}
+
+// Break is the first version of a record whose link is broken.
+type Break struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Reason    string    `json:"reason"`
+}
+
+// Digest hashes the canonical content of a version: its timestamps,
+// actor and fields, along with the record it belongs to, with an HMAC
+// keyed with key. Without a key, it is a plain sha256.
+func Digest(key Key, record model.Record) string {
+	content := map[string]interface{}{
+		"tenant":     record.Tenant,
+		"id":         record.ID,
+		"created_at": record.CreatedAt,
+		"updated_at": record.UpdatedAt,
+		"actor":      record.Actor,
+	}
+	for field, value := range record.GetData() {
+		content[field] = value
+	}
+	for field, value := range content {
+		if t, ok := value.(time.Time); ok {
+			content[field] = t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	// maps are encoded with sorted keys, which makes the encoding
+	// canonical, and strings and numbers can always be encoded
+	encoded, _ := json.Marshal(content)
+	if key == nil {
+		sum := sha256.Sum256(encoded)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Link chains the digest of a version to the hash of the previous
+// version, which is empty for the first one, with an HMAC keyed with
+// key. Without a key, e.g. in development, the link is a plain sha256,
+// which anyone can recompute.
+func Link(key Key, prev string, digest string) string {
+	if key == nil {
+		sum := sha256.Sum256([]byte(prev + ":" + digest))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(prev + ":" + digest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify walks the versions of a record, newest first as returned by
+// RecordService.GetVersions, from the oldest one, and reports the first
+// broken link. Erased versions must match one of the erasures of the
+// record, and only their hash is checked, since their content is gone.
+//
+// Digests and links are checked with key, so versions linked with
+// another key, or none, break the chain.
+func Verify(key Key, versions []model.Record, erasures []model.Erasure) Report {
+	report := Report{Versions: len(versions), Valid: true}
+	if len(versions) > 0 {
+		report.RecordID = versions[0].ID
+	}
+
+	erasedAt := map[int64]bool{}
+	for _, erasure := range erasures {
+		erasedAt[erasure.ErasedAt.Unix()] = true
+	}
+
+	prev := ""
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		reason := ""
+		switch {
+		case version.Hash == "" && prev == "":
+			report.Unchained++
+			continue
+		case version.Hash == "":
+			reason = "the version has no hash"
+		case version.ErasedAt == nil && Digest(key, version) != version.Digest:
+			reason = "the content of the version doesn't match its digest"
+		case version.ErasedAt != nil && !erasedAt[version.ErasedAt.Unix()]:
+			reason = "the version was erased without an erasure"
+		case !hmac.Equal([]byte(Link(key, prev, version.Digest)), []byte(version.Hash)):
+			reason = "the hash of the version doesn't chain to the previous version"
+		}
+
+		if reason != "" {
+			report.Valid = false
+			report.Broken = &Break{UpdatedAt: version.UpdatedAt, Reason: reason}
+			return report
+		}
+		prev = version.Hash
+	}
+	return report
+}
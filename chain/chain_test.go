@@ -0,0 +1,307 @@
+package chain_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/envelope"
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"gorm.io/gorm"
+)
+
+var now = time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+
+var key = chain.Key("the chain key of the chain tests")
+
+// setup writes three versions of record 1, a second apart.
+func setup(t *testing.T, encrypted bool) (*gorm.DB, *service.SQLiteRecordService, *clock.Fake) {
+	dir := t.TempDir()
+	db, err := model.OpenDb(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migration.NewMigrator(db, migration.SQLite).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := clock.NewFake(now)
+	records := service.NewSQLiteRecordService(db, clock)
+	records.SetChainKey(key)
+	if encrypted {
+		keyFile := filepath.Join(dir, "keys.json")
+		if _, err := envelope.AddKey(keyFile); err != nil {
+			t.Fatal(err)
+		}
+		records.SetSealer(envelope.NewCipher(envelope.NewKeyring(keyFile), model.Record{}.MutableFields()))
+	}
+
+	ctx := context.Background()
+	record, err := records.CreateRecord(ctx, 1, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, city := range []string{"Cupertino", "Palo Alto"} {
+		clock.Advance(time.Second)
+		record, err = records.UpdateRecord(ctx, record, map[string]interface{}{"city": city})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, &records, clock
+}
+
+// tamper runs sql the way an operator with access to the database
+// could, going around the append-only triggers.
+func tamper(t *testing.T, db *gorm.DB, sql string, values ...interface{}) {
+	t.Helper()
+	entry := model.Maintenance{Operation: "tamper", PerformedAt: now}
+	err := model.Maintain(db, &entry, func(tx *gorm.DB) (int, error) {
+		return 0, tx.Exec(sql, values...).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verify(t *testing.T, records *service.SQLiteRecordService, id uint) chain.Report {
+	t.Helper()
+	report, err := records.VerifyRecord(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestVerify(t *testing.T) {
+	second := now.Add(time.Second).Format(time.RFC3339)
+
+	tests := []struct {
+		name      string
+		encrypted bool
+		tamper    string
+		want      string
+	}{
+		{"Valid", false, "", ""},
+		{"ValidEncrypted", true, "", ""},
+		{"EditedContent", false,
+			"UPDATE records SET city = 'Mountain View' WHERE updated_at = '" + second + "'",
+			"the content of the version doesn't match its digest"},
+		{"DeletedVersion", false,
+			"DELETE FROM records WHERE updated_at = '" + second + "'",
+			"the hash of the version doesn't chain to the previous version"},
+		{"ClearedHash", false,
+			"UPDATE records SET hash = '' WHERE updated_at = '" + second + "'",
+			"the version has no hash"},
+		{"ErasedWithoutErasure", true,
+			"UPDATE records SET erased_at = updated_at, sealed = '' WHERE updated_at = '" + second + "'",
+			"the version was erased without an erasure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, records, _ := setup(t, tt.encrypted)
+			if tt.tamper != "" {
+				tamper(t, db, tt.tamper)
+			}
+
+			report := verify(t, records, 1)
+			if tt.want == "" {
+				if !report.Valid || report.Versions != 3 || report.Unchained != 0 {
+					t.Errorf("report = %+v, want 3 valid versions", report)
+				}
+				return
+			}
+			if report.Valid || report.Broken == nil || report.Broken.Reason != tt.want {
+				t.Fatalf("report = %+v, want broken: %s", report, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyForgedDigest(t *testing.T) {
+	db, records, _ := setup(t, false)
+
+	versions, err := records.GetVersions(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := versions[1]
+	forged.City = "Mountain View"
+	tamper(t, db, "UPDATE records SET city = ?, digest = ? WHERE updated_at = ?",
+		forged.City, chain.Digest(key, forged), forged.UpdatedAt.Format(time.RFC3339))
+
+	report := verify(t, records, 1)
+	want := "the hash of the version doesn't chain to the previous version"
+	if report.Valid || report.Broken == nil || report.Broken.Reason != want ||
+		!report.Broken.UpdatedAt.Equal(forged.UpdatedAt) {
+		t.Errorf("report = %+v, want the forged version broken", report)
+	}
+}
+
+// TestVerifyRecomputedChain edits a version and recomputes its digest
+// and the links from there on, as anyone who can edit the database could
+// without the key, whose digest gives them away.
+func TestVerifyRecomputedChain(t *testing.T) {
+	for _, forger := range []struct {
+		name string
+		key  chain.Key
+	}{
+		{"Unkeyed", nil},
+		{"OtherKey", chain.Key("another key of thirty two bytes!")},
+	} {
+		t.Run(forger.name, func(t *testing.T) {
+			db, records, _ := setup(t, false)
+
+			versions, err := records.GetVersions(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			versions[1].City = "Mountain View"
+			prev := versions[2].Hash
+			for i := 1; i >= 0; i-- {
+				version := versions[i]
+				digest := chain.Digest(forger.key, version)
+				hash := chain.Link(forger.key, prev, digest)
+				tamper(t, db, "UPDATE records SET city = ?, digest = ?, hash = ? WHERE updated_at = ?",
+					version.City, digest, hash, version.UpdatedAt.Format(time.RFC3339))
+				prev = hash
+			}
+
+			report := verify(t, records, 1)
+			want := "the content of the version doesn't match its digest"
+			if report.Valid || report.Broken == nil || report.Broken.Reason != want ||
+				!report.Broken.UpdatedAt.Equal(versions[1].UpdatedAt) {
+				t.Errorf("report = %+v, want the edited version broken", report)
+			}
+		})
+	}
+}
+
+func TestVerifyUnchained(t *testing.T) {
+	db, records, clock := setup(t, false)
+
+	// a version written before versions were chained
+	ctx := context.Background()
+	record, err := records.CreateRecord(ctx, 2, map[string]interface{}{"first_name": "Jane"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamper(t, db, "UPDATE records SET digest = '', hash = '' WHERE id = 2")
+	clock.Advance(time.Second)
+	_, err = records.UpdateRecord(ctx, record, map[string]interface{}{"city": "Cupertino"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := verify(t, records, 2)
+	if !report.Valid || report.Versions != 2 || report.Unchained != 1 {
+		t.Errorf("report = %+v, want the chain to start at the second version", report)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	third := now.Add(2 * time.Second).Format(time.RFC3339)
+
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, db *gorm.DB, records *service.SQLiteRecordService)
+		deleted bool
+		want    string
+	}{
+		{"Unchanged", nil, false, ""},
+		{"NewVersion", func(t *testing.T, db *gorm.DB, records *service.SQLiteRecordService) {
+			record, err := records.GetRecord(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = records.UpdateRecord(context.Background(), record, map[string]interface{}{"city": "Los Altos"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, false, ""},
+		{"Correction", func(t *testing.T, db *gorm.DB, records *service.SQLiteRecordService) {
+			_, err := records.CorrectVersion(context.Background(), 1, now, map[string]interface{}{"first_name": "Steven"}, "typo")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, false, ""},
+		{"DeletedLatestVersion", func(t *testing.T, db *gorm.DB, records *service.SQLiteRecordService) {
+			tamper(t, db, "DELETE FROM records WHERE updated_at = '"+third+"'")
+		}, false, "versions of the record were deleted since the checkpoint"},
+		{"ReplacedLatestVersion", func(t *testing.T, db *gorm.DB, records *service.SQLiteRecordService) {
+			tamper(t, db, "UPDATE records SET hash = 'replaced' WHERE updated_at = '"+third+"'")
+		}, false, "the latest version at the checkpoint was replaced"},
+		{"DeletedRecord", nil, true, "versions of the record were deleted since the checkpoint"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, records, clock := setup(t, false)
+			versions, err := records.GetVersions(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkpoint := chain.NewCheckpoint(key, clock.Now(), []chain.Head{chain.HeadOf(versions)})
+
+			clock.Advance(time.Second)
+			if tt.change != nil {
+				tt.change(t, db, records)
+			}
+
+			versions, err = records.GetVersions(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.deleted {
+				versions = nil
+			}
+			maintenance, err := records.GetMaintenance(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := checkpoint.Heads[0].Check(versions, maintenance, checkpoint.TakenAt)
+			if got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointValid(t *testing.T) {
+	head := chain.Head{Tenant: "acme", RecordID: 1, Versions: 3, Hash: "abc"}
+	checkpoint := chain.NewCheckpoint(key, now, []chain.Head{head})
+	if !checkpoint.Valid(key) {
+		t.Errorf("Valid = false, want true")
+	}
+	if checkpoint.Valid(chain.Key("another key of thirty two bytes!")) {
+		t.Errorf("Valid with another key = true, want false")
+	}
+
+	checkpoint.Heads[0].Versions = 2
+	if checkpoint.Valid(key) {
+		t.Errorf("Valid after removing a version = true, want false")
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.key")
+	if err := chain.GenerateKey(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.GenerateKey(path); err == nil {
+		t.Errorf("GenerateKey over an existing key err = nil, want an error")
+	}
+
+	loaded, err := chain.LoadKey(path)
+	if err != nil || len(loaded) != 32 {
+		t.Errorf("LoadKey = %x, %v, want a key of 32 bytes", loaded, err)
+	}
+}
@@ -0,0 +1,85 @@
+package chain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// Head is the latest version of a record when a checkpoint was taken.
+type Head struct {
+	Tenant   string `json:"tenant"`
+	RecordID uint   `json:"record_id"`
+	Versions int    `json:"versions"`
+	Hash     string `json:"hash"`
+}
+
+// HeadOf is the head of the versions of a record, newest first as
+// returned by RecordService.GetVersions.
+func HeadOf(versions []model.Record) Head {
+	if len(versions) == 0 {
+		return Head{}
+	}
+	return Head{
+		Tenant:   versions[0].Tenant,
+		RecordID: versions[0].ID,
+		Versions: len(versions),
+		Hash:     versions[0].Hash,
+	}
+}
+
+// Check compares the versions of the record of h, newest first, to h,
+// and returns why they don't match, or an empty string. The versions up
+// to the head must still be there, with the hash of the head, unless a
+// correction after the checkpoint chained them again, see maintenance.
+func (h Head) Check(versions []model.Record, maintenance []model.Maintenance, takenAt time.Time) string {
+	if len(versions) < h.Versions {
+		return "versions of the record were deleted since the checkpoint"
+	}
+	if versions[len(versions)-h.Versions].Hash == h.Hash {
+		return ""
+	}
+	for _, entry := range maintenance {
+		if entry.Operation == model.MaintenanceCorrection && entry.PerformedAt.After(takenAt) {
+			return ""
+		}
+	}
+	return "the latest version at the checkpoint was replaced"
+}
+
+// Checkpoint is the heads of the chains of the records at a time, to be
+// kept outside of the database, so that the versions deleted since can
+// be detected. Its MAC is keyed with the key of the chain.
+type Checkpoint struct {
+	TakenAt time.Time `json:"taken_at"`
+	Heads   []Head    `json:"heads"`
+	MAC     string    `json:"mac"`
+}
+
+// NewCheckpoint makes the checkpoint of heads at takenAt.
+func NewCheckpoint(key Key, takenAt time.Time, heads []Head) Checkpoint {
+	c := Checkpoint{TakenAt: takenAt.UTC(), Heads: heads}
+	c.MAC = c.mac(key)
+	return c
+}
+
+// Valid is true when c was made with key and wasn't changed since.
+func (c Checkpoint) Valid(key Key) bool {
+	return hmac.Equal([]byte(c.mac(key)), []byte(c.MAC))
+}
+
+func (c Checkpoint) mac(key Key) string {
+	// the fields are encoded in their order, and times in RFC3339
+	encoded, _ := json.Marshal(struct {
+		TakenAt string `json:"taken_at"`
+		Heads   []Head `json:"heads"`
+	}{c.TakenAt.UTC().Format(time.RFC3339Nano), c.Heads})
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil))
+}
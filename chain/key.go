@@ -0,0 +1,53 @@
+package chain
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keySize is the size of the keys made by GenerateKey, in bytes.
+const keySize = 32
+
+// Key is the secret keying the links of the chain. It must be kept
+// outside of the database, or anyone who can edit a version can also
+// recompute its link and those after it.
+type Key []byte
+
+// GenerateKey writes a new random key to path, which must not exist, in
+// base64.
+func GenerateKey(path string) error {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// LoadKey reads the key written by GenerateKey at path.
+func LoadKey(path string) (Key, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a base64 key: %w", path, err)
+	}
+	if len(key) < keySize {
+		return nil, fmt.Errorf("%s has a key of %d bytes, want at least %d", path, len(key), keySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/config"
+	"github.com/rs/zerolog/log"
+)
+
+const chainUsage = "usage: timetravel --chain-key-file <file> chain keygen"
+
+// runChain implements `timetravel chain keygen`, which creates the key
+// of the links of the hash chain of versions.
+func runChain(c config.Config, args []string) {
+	if len(args) != 1 || args[0] != "keygen" || c.ChainKeyFile == "" {
+		log.Fatal().Msg(chainUsage)
+	}
+	err := chain.GenerateKey(c.ChainKeyFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not create the chain key")
+	}
+	log.Info().Msgf("created the chain key %s; keep it outside of the database, along with a copy", c.ChainKeyFile)
+}
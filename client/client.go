@@ -0,0 +1,178 @@
+// Package client is a Go client for the /api/v2 records API.
+//
+//	c := client.New("http://127.0.0.1:8000")
+//	record, err := c.GetAt(ctx, 30, at)
+//	if errors.Is(err, client.ErrRecordDoesNotExist) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// Client calls the /api/v2 records endpoints of a timetravel server.
+//
+// Requests that fail with a network error or a 429, 502, 503 or 504
+// status are retried up to MaxRetries times with exponential backoff,
+// waiting at least as long as the Retry-After header of the response.
+// Retrying an upsert is safe, since posting the same data twice doesn't
+// create a new version.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	MaxRetries int
+	Backoff    time.Duration
+
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+
+	// Tenant is sent in the X-Tenant-ID header when set.
+	Tenant string
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: 3,
+		Backoff:    100 * time.Millisecond,
+	}
+}
+
+// Get retrieves the latest version of a record.
+func (c *Client) Get(ctx context.Context, id uint) (model.RecordJSON, error) {
+	var record model.RecordJSON
+	err := c.do(ctx, http.MethodGet, recordPath(id), nil, &record)
+	return record, err
+}
+
+// GetAt retrieves the version of a record as it was at the given time.
+func (c *Client) GetAt(ctx context.Context, id uint, at time.Time) (model.RecordJSON, error) {
+	var record model.RecordJSON
+	path := recordPath(id) + "?at=" + url.QueryEscape(at.Format(time.RFC3339))
+	err := c.do(ctx, http.MethodGet, path, nil, &record)
+	return record, err
+}
+
+// Upsert creates the record, or updates it when it already exists.
+// A nil value deletes that field of the record.
+func (c *Client) Upsert(ctx context.Context, id uint, data map[string]interface{}) (model.RecordJSON, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return model.RecordJSON{}, err
+	}
+
+	var record model.RecordJSON
+	err = c.do(ctx, http.MethodPost, recordPath(id), body, &record)
+	return record, err
+}
+
+// Versions retrieves every version of a record, newest first.
+func (c *Client) Versions(ctx context.Context, id uint) ([]model.RecordJSON, error) {
+	var records []model.RecordJSON
+	err := c.do(ctx, http.MethodGet, recordPath(id)+"/versions", nil, &records)
+	return records, err
+}
+
+func recordPath(id uint) string {
+	return fmt.Sprintf("/api/v2/records/%d", id)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body []byte, out interface{}) error {
+	backoff := c.Backoff
+
+	for attempt := 0; ; attempt++ {
+		err := c.doOnce(ctx, method, path, body, out)
+		if err == nil || attempt >= c.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		wait := backoff
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, method string, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	if c.Tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.Tenant)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return &networkError{err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &networkError{err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newError(resp, respBody)
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// networkError is a failure to get a response, which is retried.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+func retryable(err error) bool {
+	if _, ok := err.(*networkError); ok {
+		return true
+	}
+	if e, ok := err.(*Error); ok {
+		switch e.StatusCode {
+		case http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/client"
+	"github.com/rainbowmga/timetravel/client/clienttest"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/limit"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+
+	server := clienttest.NewServer(start)
+	defer server.Close()
+	c := server.Client()
+
+	_, err := c.Get(ctx, 30)
+	if !errors.Is(err, client.ErrRecordDoesNotExist) {
+		t.Fatalf("Get error = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	created, err := c.Upsert(ctx, 30, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if created.ID != 30 || created.Data["first_name"] != "Steve" {
+		t.Errorf("created = %+v", created)
+	}
+
+	server.Clock.Advance(time.Minute)
+	_, err = c.Upsert(ctx, 30, map[string]interface{}{"first_name": "Steven"})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	latest, err := c.Get(ctx, 30)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if latest.Data["first_name"] != "Steven" {
+		t.Errorf("latest first_name = %v, want Steven", latest.Data["first_name"])
+	}
+
+	old, err := c.GetAt(ctx, 30, start.Add(time.Second))
+	if err != nil {
+		t.Fatalf("GetAt: %v", err)
+	}
+	if old.Data["first_name"] != "Steve" {
+		t.Errorf("old first_name = %v, want Steve", old.Data["first_name"])
+	}
+
+	versions, err := c.Versions(ctx, 30)
+	if err != nil {
+		t.Fatalf("Versions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("len(versions) = %d, want 2", len(versions))
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":1,"data":{"first_name":"Steve"}}`))
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL)
+	c.Backoff = time.Millisecond
+
+	record, err := c.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if attempts != 3 || record.ID != 1 {
+		t.Errorf("attempts = %d, record = %+v", attempts, record)
+	}
+}
+
+func TestClientDoesNotRetryBadRequests(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid id; id must be a positive number"}`))
+	}))
+	defer server.Close()
+
+	_, err := client.New(server.URL).Get(context.Background(), 1)
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("error = %v, want a 400 *client.Error", err)
+	}
+	if !errors.Is(err, client.ErrInvalidID) {
+		t.Errorf("error = %v, want ErrInvalidID", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+
+	server := clienttest.NewServer(start,
+		clienttest.WithAPIKey("tt_reader", auth.ScopeRead),
+		clienttest.WithAPIKey("tt_writer", auth.ScopeRead, auth.ScopeWrite),
+		clienttest.WithLimits(limit.Limits{Rate: 1, Burst: 1, MaxBodyBytes: 64}),
+	)
+	defer server.Close()
+
+	c := server.Client()
+	c.MaxRetries = 0
+
+	tests := []struct {
+		name   string
+		key    string
+		data   map[string]interface{}
+		want   error
+		status int
+	}{
+		{"invalid key", "tt_unknown", nil, client.ErrUnauthorized, http.StatusUnauthorized},
+		{"missing scope", "tt_reader", map[string]interface{}{"first_name": "Steve"}, client.ErrForbidden, http.StatusForbidden},
+		{"large body", "tt_writer", map[string]interface{}{"first_name": strings.Repeat("e", 64)}, client.ErrBodyTooLarge, http.StatusRequestEntityTooLarge},
+		{"over the rate", "tt_writer", map[string]interface{}{"first_name": "Steve"}, client.ErrRateLimited, http.StatusTooManyRequests},
+	}
+	for _, test := range tests {
+		c.APIKey = test.key
+		var err error
+		if test.data == nil {
+			_, err = c.Get(ctx, 30)
+		} else {
+			_, err = c.Upsert(ctx, 30, test.data)
+		}
+
+		var apiErr *client.Error
+		if !errors.Is(err, test.want) || !errors.As(err, &apiErr) || apiErr.StatusCode != test.status {
+			t.Errorf("%s: error = %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	var apiErr *client.Error
+	_, err := c.Get(ctx, 30)
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Second {
+		t.Errorf("error = %v, want a 429 to retry after a second", err)
+	}
+
+	server.Clock.Advance(time.Second)
+	_, err = c.Upsert(ctx, 30, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Errorf("Upsert once refilled: %v", err)
+	}
+}
+
+func TestClientRetriesAfterRateLimit(t *testing.T) {
+	var attempts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, time.Now())
+		if len(attempts) < 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"too many requests; retry after 1s"}`))
+			return
+		}
+		w.Write([]byte(`{"id":1,"data":{"first_name":"Steve"}}`))
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL)
+	c.Backoff = time.Millisecond
+
+	_, err := c.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("attempts = %d, want 2", len(attempts))
+	}
+	if waited := attempts[1].Sub(attempts[0]); waited < time.Second {
+		t.Errorf("retried after %s, want at least the second of Retry-After", waited)
+	}
+}
@@ -0,0 +1,98 @@
+// Package clienttest provides an in-process timetravel server for
+// testing code that uses the client package.
+package clienttest
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api"
+	"github.com/rainbowmga/timetravel/client"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/limit"
+	"github.com/rainbowmga/timetravel/middleware"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+// Server is the real API backed by in-memory storage and a fake clock.
+type Server struct {
+	*httptest.Server
+
+	// Clock controls the timestamps of new versions, and the refill of
+	// the rate limits.
+	Clock *clock.Fake
+}
+
+// Option changes the server started by NewServer.
+type Option func(*options)
+
+type options struct {
+	keys   apiKeys
+	limits limit.Limits
+}
+
+// WithAPIKey requires callers to authenticate, and accepts key with
+// scopes. Without it, every caller is let in.
+func WithAPIKey(key string, scopes ...string) Option {
+	return func(o *options) {
+		if o.keys == nil {
+			o.keys = apiKeys{}
+		}
+		o.keys[key] = auth.Principal{Subject: "apikey:" + key, Scopes: scopes}
+	}
+}
+
+// WithLimits limits the requests to /api/v2, whose rate is measured by
+// the clock of the server.
+func WithLimits(limits limit.Limits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+// NewServer starts a server whose clock is set to now. Close it when
+// done.
+func NewServer(now time.Time, opts ...Option) *Server {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	clock := clock.NewFake(now)
+	records := service.NewMemoryRecordService(clock)
+
+	router := mux.NewRouter()
+	a := api.NewAPI(&records, service.NewChangeFeed(), nil, nil, nil)
+	a.SetLimits("v2", o.limits, clock)
+	a.CreateRoutes(router.PathPrefix("/api").Subrouter())
+
+	// a nil authenticator lets every caller in
+	var authenticator auth.Authenticator
+	if o.keys != nil {
+		authenticator = o.keys
+	}
+	handler := middleware.AuthMiddleware(authenticator)(middleware.TenantMiddleware(router))
+
+	return &Server{httptest.NewServer(handler), clock}
+}
+
+// Client returns a client of the server.
+func (s *Server) Client() *client.Client {
+	c := client.New(s.URL)
+	c.HTTPClient = s.Server.Client()
+	return c
+}
+
+// apiKeys authenticates the keys of WithAPIKey.
+type apiKeys map[string]auth.Principal
+
+func (k apiKeys) Authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	principal, ok := k[token]
+	if !ok {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	return principal, nil
+}
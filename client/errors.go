@@ -0,0 +1,108 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The errors returned by the server. Compare them with errors.Is:
+//
+//	if errors.Is(err, client.ErrRecordDoesNotExist) {
+var (
+	ErrRecordDoesNotExist = errors.New("record does not exist")
+	ErrInvalidID          = errors.New("invalid id")
+	ErrInvalidTime        = errors.New("invalid time")
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrBodyTooLarge       = errors.New("request body too large")
+	ErrRateLimited        = errors.New("too many requests")
+	ErrInternal           = errors.New("internal error")
+)
+
+// Error is a non 200 response of the server.
+type Error struct {
+	StatusCode int
+
+	// Message is the `error` of the response body.
+	Message string
+
+	// RetryAfter is how long the server asked to wait before retrying,
+	// from the Retry-After header of 429 and 503 responses.
+	RetryAfter time.Duration
+
+	kind error
+}
+
+func newError(resp *http.Response, body []byte) *Error {
+	var payload struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+		message = payload.Error
+	}
+
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		kind:       classify(resp.StatusCode, message),
+	}
+}
+
+// classify maps the status codes of the responses to sentinels. The
+// server responds with a 400 to every invalid request, which are told
+// apart by the messages of concern/response errors.
+func classify(statusCode int, message string) error {
+	switch {
+	case statusCode >= http.StatusInternalServerError:
+		return ErrInternal
+	case statusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		return ErrForbidden
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrBodyTooLarge
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode != http.StatusBadRequest:
+		return nil
+	case strings.HasPrefix(message, "record of id") &&
+		strings.HasSuffix(message, "does not exist"):
+		return ErrRecordDoesNotExist
+	case strings.HasPrefix(message, "invalid id"):
+		return ErrInvalidID
+	case strings.HasPrefix(message, "invalid time"):
+		return ErrInvalidTime
+	}
+	return ErrInvalidInput
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an http
+// date, into how long to wait from now.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("timetravel: %d %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
@@ -0,0 +1,88 @@
+// Package auth holds the identity of the caller of a request.
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var ErrUnauthenticated = errors.New("invalid credentials")
+
+// Scopes of the api. Admin grants every other scope.
+const (
+	ScopeRead    = "read"
+	ScopeWrite   = "write"
+	ScopeHistory = "history"
+	ScopeAdmin   = "admin"
+)
+
+// Scopes lists every scope.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeHistory, ScopeAdmin}
+
+// IsScope is true for the known scopes.
+func IsScope(scope string) bool {
+	return contains(Scopes, scope)
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	Scopes  []string
+
+	// Roles are those granted by the identity provider, if any.
+	Roles []string
+
+	// Tenant binds the principal to the records of a tenant, when set.
+	Tenant string
+
+	// Fields restricts the fields the principal can access, when it is
+	// not nil.
+	Fields *FieldAccess
+}
+
+// Anonymous is the caller of every request when authentication is
+// disabled.
+var Anonymous = Principal{Subject: "anonymous", Scopes: []string{ScopeAdmin}}
+
+// Can reports whether the principal was granted every one of scopes.
+func (p Principal) Can(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !p.has(scope) && !p.has(ScopeAdmin) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Principal) has(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+// Authenticator resolves the principal presenting a token, or fails with
+// ErrUnauthenticated.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (Principal, error)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of a request, and whether it was
+// authenticated.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// BearerToken extracts the token of an `Authorization: Bearer` header.
+func BearerToken(authorization string) string {
+	const prefix = "bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return ""
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// FieldAccess lists the fields of records, among MutableFields, that a
+// principal can read and write.
+type FieldAccess struct {
+	Readable []string `json:"readable"`
+	Writable []string `json:"writable"`
+}
+
+// FieldRoles maps roles to the fields they give access to. A principal
+// with several of the roles can access the fields of any of them, and
+// other roles give access to no field. Only principals without roles,
+// but those named after scopes, are not restricted.
+type FieldRoles map[string]FieldAccess
+
+// DefaultFieldRoles lets support staff see and fix addresses, but not
+// the personal details of people.
+var DefaultFieldRoles = FieldRoles{
+	"support": {
+		Readable: []string{"first_name", "middle_name", "last_name", "street", "city", "state", "zip", "country"},
+		Writable: []string{"street", "city", "state", "zip", "country"},
+	},
+}
+
+// LoadFieldRoles reads field roles from a json file of the same shape
+// as FieldRoles.
+func LoadFieldRoles(path string) (FieldRoles, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := FieldRoles{}
+	err = json.Unmarshal(content, &roles)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for role, access := range roles {
+		for _, field := range append(append([]string{}, access.Readable...), access.Writable...) {
+			if !isMutableField(field) {
+				return nil, fmt.Errorf("%s: role %q: unknown field %q", path, role, field)
+			}
+		}
+	}
+	return roles, nil
+}
+
+// Restrict resolves the field access of the principals authenticated by
+// authenticator.
+func (roles FieldRoles) Restrict(authenticator Authenticator) Authenticator {
+	return &restricted{authenticator, roles}
+}
+
+type restricted struct {
+	Authenticator
+	roles FieldRoles
+}
+
+func (r *restricted) Authenticate(ctx context.Context, token string) (Principal, error) {
+	principal, err := r.Authenticator.Authenticate(ctx, token)
+	if err != nil {
+		return principal, err
+	}
+	principal.Fields = r.roles.access(principal.Roles)
+	return principal, nil
+}
+
+// access merges the fields of the roles, or is nil when there are none
+// but those named after scopes.
+func (roles FieldRoles) access(names []string) *FieldAccess {
+	var merged *FieldAccess
+	for _, name := range names {
+		access, ok := roles[name]
+		if !ok && IsScope(name) {
+			continue
+		}
+		if merged == nil {
+			merged = &FieldAccess{Readable: []string{}, Writable: []string{}}
+		}
+		merged.Readable = append(merged.Readable, access.Readable...)
+		merged.Writable = append(merged.Writable, access.Writable...)
+	}
+	return merged
+}
+
+// Unreadable lists the mutable fields the principal can't read.
+func (p Principal) Unreadable() []string {
+	if p.Fields == nil {
+		return nil
+	}
+	return excluding(model.Record{}.MutableFields(), p.Fields.Readable)
+}
+
+// Unwritable lists the fields of a payload that the principal can't
+// write, in order. Fields other than the mutable fields are ignored.
+func (p Principal) Unwritable(data map[string]interface{}) []string {
+	if p.Fields == nil {
+		return nil
+	}
+
+	mutable := []string{}
+	for field := range data {
+		if isMutableField(field) {
+			mutable = append(mutable, field)
+		}
+	}
+	unwritable := excluding(mutable, p.Fields.Writable)
+	sort.Strings(unwritable)
+	return unwritable
+}
+
+// Ungrantable lists the roles the principal can't grant to api keys, in
+// order: those it doesn't have, unless it is not restricted.
+func (p Principal) Ungrantable(roles []string) []string {
+	if p.Fields == nil {
+		return nil
+	}
+	ungrantable := excluding(roles, p.Roles)
+	sort.Strings(ungrantable)
+	return ungrantable
+}
+
+// ForbiddenFields is the error message of a write to unwritable fields.
+func ForbiddenFields(fields []string) string {
+	return "forbidden; can't write " + strings.Join(fields, ", ")
+}
+
+// ForbiddenRoles is the error message of a grant of ungrantable roles.
+func ForbiddenRoles(roles []string) string {
+	return "forbidden; can't grant " + strings.Join(roles, ", ")
+}
+
+// Unreadable lists the mutable fields the principal of ctx can't read,
+// if any.
+func Unreadable(ctx context.Context) []string {
+	principal, _ := FromContext(ctx)
+	return principal.Unreadable()
+}
+
+func excluding(fields []string, allowed []string) []string {
+	excluded := []string{}
+	for _, field := range fields {
+		if !contains(allowed, field) {
+			excluded = append(excluded, field)
+		}
+	}
+	return excluded
+}
+
+func isMutableField(field string) bool {
+	return contains(model.Record{}.MutableFields(), field)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
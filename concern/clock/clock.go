@@ -0,0 +1,69 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Services take a Clock instead of
+// calling time.Now so that time travel can be tested deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+// Real returns the system clock.
+func Real() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+type offsetClock struct {
+	clock  Clock
+	offset time.Duration
+}
+
+// Offset returns a clock that runs offset ahead of c, or behind it if
+// offset is negative.
+func Offset(c Clock, offset time.Duration) Clock {
+	return offsetClock{c, offset}
+}
+
+func (c offsetClock) Now() time.Time {
+	return c.clock.Now().Add(c.offset)
+}
+
+// Fake is a clock that only moves when told to. It is safe for
+// concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to now.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,153 @@
+// Package limit limits the rate of the requests of every caller, over
+// http and gRPC alike, with a token bucket per caller.
+package limit
+
+import (
+	"expvar"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/clock"
+)
+
+// Limits are the limits of the requests to a group of routes. Their
+// zero values disable them.
+type Limits struct {
+	// Rate is how many requests per second every caller can make on
+	// average, and Burst how many at once.
+	Rate  float64
+	Burst int
+
+	// MaxBodyBytes is the size of the largest body accepted.
+	MaxBodyBytes int64
+}
+
+// Rejections counts the rejected requests, by group of routes and
+// reason, e.g. `v2.rate_limited` or `v1.body_too_large`.
+var Rejections = expvar.NewMap("rejections")
+
+// Limiter holds the token bucket of every caller: a bucket of Burst
+// tokens, refilled at Rate tokens per second of clock, from which every
+// request takes one. A Limiter without a rate lets every request in.
+type Limiter struct {
+	rate  float64
+	burst float64
+	clock clock.Clock
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(limits Limits, clock clock.Clock) *Limiter {
+	return &Limiter{
+		rate:    limits.Rate,
+		burst:   math.Max(float64(limits.Burst), 1),
+		clock:   clock,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take takes a token from the bucket of caller, or returns how long
+// until it has one.
+func (l *Limiter) Take(caller string) (time.Duration, bool) {
+	if l.rate <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.bucketOf(caller)
+	if current.tokens < 1 {
+		return l.wait(current), false
+	}
+	current.tokens--
+	return 0, true
+}
+
+// Check tells whether the bucket of caller has a token left, without
+// taking it, or returns how long until it has one. It lets requests in
+// whose cost is only known afterwards, see Charge.
+func (l *Limiter) Check(caller string) (time.Duration, bool) {
+	if l.rate <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.bucketOf(caller)
+	if current.tokens < 1 {
+		return l.wait(current), false
+	}
+	return 0, true
+}
+
+// Charge takes a token from the bucket of caller for a request that
+// turned out to count, e.g. one that failed to authenticate, if it has
+// one left.
+func (l *Limiter) Charge(caller string) {
+	if l.rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.bucketOf(caller)
+	current.tokens = math.Max(0, current.tokens-1)
+}
+
+// RetryAfter is wait in whole seconds, rounded up, for the Retry-After
+// header of rejected requests.
+func RetryAfter(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// bucketOf returns the bucket of caller, refilled until now. l.mu must
+// be held.
+func (l *Limiter) bucketOf(caller string) *bucket {
+	now := l.clock.Now()
+	l.sweep(now)
+
+	current, ok := l.buckets[caller]
+	if !ok {
+		current = &bucket{tokens: l.burst, last: now}
+		l.buckets[caller] = current
+	}
+	current.tokens = l.refill(current, now)
+	current.last = now
+	return current
+}
+
+// wait is how long until a bucket has a token.
+func (l *Limiter) wait(current *bucket) time.Duration {
+	return time.Duration((1 - current.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) refill(current *bucket, now time.Time) float64 {
+	elapsed := now.Sub(current.last).Seconds()
+	return math.Min(l.burst, current.tokens+elapsed*l.rate)
+}
+
+// sweep forgets the callers whose bucket is full again, at most once a
+// minute, since they are the same as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for caller, current := range l.buckets {
+		if l.refill(current, now) >= l.burst {
+			delete(l.buckets, caller)
+		}
+	}
+}
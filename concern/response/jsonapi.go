@@ -0,0 +1,51 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// JSONAPIContentType is the media type of https://jsonapi.org documents.
+const JSONAPIContentType = "application/vnd.api+json"
+
+type jsonAPIKey struct{}
+
+// WithJSONAPI marks a request context as having negotiated JSON:API
+// responses.
+func WithJSONAPI(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jsonAPIKey{}, true)
+}
+
+// IsJSONAPI reports whether the request negotiated JSON:API responses.
+func IsJSONAPI(r *http.Request) bool {
+	ok, _ := r.Context().Value(jsonAPIKey{}).(bool)
+	return ok
+}
+
+// WriteJSONAPI writes the document with the JSON:API content type.
+func WriteJSONAPI(w http.ResponseWriter, document interface{}, statusCode int) error {
+	w.Header().Add("Content-Type", JSONAPIContentType)
+	w.WriteHeader(statusCode)
+	return json.NewEncoder(w).Encode(document)
+}
+
+// WriteErrorFor writes the message as an error in the format negotiated
+// by the request: a JSON:API errors document, or the usual error json.
+func WriteErrorFor(w http.ResponseWriter, r *http.Request, message string, statusCode int) error {
+	if !IsJSONAPI(r) {
+		return WriteError(w, message, statusCode)
+	}
+
+	return WriteJSONAPI(
+		w,
+		map[string]interface{}{
+			"errors": []map[string]string{{
+				"status": strconv.Itoa(statusCode),
+				"detail": message,
+			}},
+		},
+		statusCode,
+	)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/rainbowmga/timetravel/concern/auth"
 	"github.com/rainbowmga/timetravel/concern/logging"
 	"github.com/rainbowmga/timetravel/model"
 	"github.com/rs/zerolog/log"
@@ -32,8 +33,10 @@ func WriteError(w http.ResponseWriter, message string, statusCode int) error {
 	)
 }
 
-func WriteRecord(w http.ResponseWriter, record model.Record) {
-	recordJson, err := record.ToJSON()
+// WriteRecord writes a record, without the fields the principal of the
+// request can't read, nor the omitted ones.
+func WriteRecord(w http.ResponseWriter, r *http.Request, record model.Record, omit ...string) {
+	recordJson, err := record.ToJSON(append(auth.Unreadable(r.Context()), omit...)...)
 	if err != nil {
 		err := WriteError(w, "internal error", http.StatusInternalServerError)
 		logging.LogError(err)
@@ -49,10 +52,12 @@ func WriteRecord(w http.ResponseWriter, record model.Record) {
 	logging.LogError(err)
 }
 
-func WriteRecords(w http.ResponseWriter, records []model.Record) {
+// WriteRecords writes records like WriteRecord.
+func WriteRecords(w http.ResponseWriter, r *http.Request, records []model.Record) {
+	omit := auth.Unreadable(r.Context())
 	recordsJson := make([]interface{}, len(records))
 	for i, record := range records {
-		recordJson, err := record.ToJSON()
+		recordJson, err := record.ToJSON(omit...)
 		if err != nil {
 			err := WriteError(w, "internal error", http.StatusInternalServerError)
 			logging.LogError(err)
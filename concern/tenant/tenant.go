@@ -0,0 +1,78 @@
+// Package tenant holds the tenant whose records a request reads and
+// writes. Every tenant has its own records, which other tenants can't
+// see, even with the same ids.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+)
+
+var ErrInvalidTenant = errors.New("invalid tenant; tenant must be lowercase letters, digits, - or _")
+var ErrForbiddenTenant = errors.New("forbidden; credentials are for another tenant")
+var ErrTenantRequiresAdmin = errors.New("forbidden; naming a tenant requires the admin scope")
+
+// Default is the tenant of requests which don't name one, and of the
+// records written before tenants existed.
+const Default = "default"
+
+// Header names the tenant of a request, for admins which aren't bound
+// to one.
+const Header = "X-Tenant-ID"
+
+// names are safe to use in file names.
+var names = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// IsValid is true for the names allowed for tenants.
+func IsValid(name string) bool {
+	return names.MatchString(name)
+}
+
+// Resolve returns the tenant of a request made by principal, naming
+// requested, if any. A principal bound to a tenant can only request it,
+// admins which aren't can request any tenant, and the others only
+// Default.
+func Resolve(principal auth.Principal, requested string) (string, error) {
+	if requested != "" && !IsValid(requested) {
+		return "", ErrInvalidTenant
+	}
+
+	if principal.Tenant != "" {
+		if requested != "" && requested != principal.Tenant {
+			return "", ErrForbiddenTenant
+		}
+		return principal.Tenant, nil
+	}
+
+	if requested == "" || requested == Default {
+		return Default, nil
+	}
+	if !principal.Can(auth.ScopeAdmin) {
+		return "", ErrTenantRequiresAdmin
+	}
+	return requested, nil
+}
+
+// IsForbidden is true for the errors of Resolve which deny the principal
+// the tenant it requested, rather than reject its name.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbiddenTenant) || errors.Is(err, ErrTenantRequiresAdmin)
+}
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext returns the tenant of a request, or Default.
+func FromContext(ctx context.Context) string {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	if !ok {
+		return Default
+	}
+	return tenant
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os/user"
+
+	"github.com/rainbowmga/timetravel/concern/config"
+	"github.com/rainbowmga/timetravel/envelope"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const encryptionUsage = "usage: timetravel --encryption-key-file <file> encryption rotate"
+
+// runEncryption implements `timetravel encryption rotate`, which adds a
+// key to the key file, creating it as needed, rewraps the data keys of
+// the records with it, and encrypts the fields left in plaintext.
+func runEncryption(c config.Config, store storage, args []string) {
+	if len(args) != 1 || args[0] != "rotate" || store.cipher == nil {
+		log.Fatal().Msg(encryptionUsage)
+	}
+
+	key, err := envelope.AddKey(c.EncryptionKeyFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not add a key to the key file")
+	}
+	log.Info().Msgf("added key %s to %s", key.ID, c.EncryptionKeyFile)
+
+	dbs := []*gorm.DB{store.db}
+	if c.SQLiteTenantDir != "" {
+		dbs, err = tenantDbs(c.SQLiteTenantDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not open the tenant databases")
+		}
+	}
+
+	rewrapped, sealed := 0, 0
+	for _, db := range dbs {
+		count, err := store.cipher.Rewrap(db)
+		rewrapped += count
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not rewrap the data keys")
+		}
+		count, err = store.cipher.SealPlaintext(db, store.clock, operator())
+		sealed += count
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not encrypt the plaintext versions")
+		}
+	}
+	log.Info().Msgf("rewrapped %d data keys and encrypted %d plaintext versions", rewrapped, sealed)
+}
+
+// operator is the user running a command, to which the maintenance of
+// the records it performs is attributed.
+func operator() string {
+	current, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return current.Username
+}
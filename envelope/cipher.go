@@ -0,0 +1,363 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNoDataKey = errors.New("the record has no data key")
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// sealedPrefix versions the format of the sealed fields of records.
+const sealedPrefix = "v1:"
+
+// dataKey is the data key of a record, wrapped by a key of the key file.
+type dataKey struct {
+	Tenant    string `gorm:"primaryKey"`
+	RecordID  uint   `gorm:"primaryKey;autoIncrement:false"`
+	KeyID     string
+	Wrapped   []byte
+	CreatedAt time.Time
+}
+
+func (dataKey) TableName() string {
+	return "record_keys"
+}
+
+// Cipher encrypts the configured fields of the versions of records into
+// their sealed column, leaving the plaintext columns empty.
+type Cipher struct {
+	keys   *Keyring
+	fields []string
+}
+
+func NewCipher(keys *Keyring, fields []string) Cipher {
+	return Cipher{keys, fields}
+}
+
+// Check fails when the key file can't be read, or has no keys.
+func (c Cipher) Check() error {
+	_, err := c.keys.Primary()
+	return err
+}
+
+// Seal replaces the encrypted fields of the data of a version by its
+// sealed column, creating the data key of the record as needed. Empty
+// fields are left out.
+func (c Cipher) Seal(tx *gorm.DB, tenant string, id uint, data map[string]interface{}) error {
+	plain := map[string]interface{}{}
+	for _, field := range c.fields {
+		value, ok := data[field]
+		if !ok {
+			continue
+		}
+		if value != nil && !reflect.ValueOf(value).IsZero() {
+			plain[field] = value
+		}
+		data[field] = nil
+	}
+
+	data["sealed"] = ""
+	if len(plain) == 0 {
+		return nil
+	}
+
+	dek, err := c.dataKey(tx, tenant, id, true)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(plain)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := encrypt(dek, content, recordAAD(tenant, id))
+	if err != nil {
+		return err
+	}
+	data["sealed"] = sealedPrefix + base64.StdEncoding.EncodeToString(ciphertext)
+	return nil
+}
+
+// Open decrypts the sealed fields of records read from db in place.
+func (c Cipher) Open(db *gorm.DB, records ...*model.Record) error {
+	deks := map[string][]byte{}
+	for _, record := range records {
+		if record.Sealed == "" {
+			continue
+		}
+
+		aad := recordAAD(record.Tenant, record.ID)
+		dek, ok := deks[string(aad)]
+		if !ok {
+			var err error
+			dek, err = c.dataKey(db, record.Tenant, record.ID, false)
+			if err != nil {
+				return err
+			}
+			deks[string(aad)] = dek
+		}
+
+		encoded, ok := strings.CutPrefix(record.Sealed, sealedPrefix)
+		if !ok {
+			return ErrInvalidCiphertext
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ErrInvalidCiphertext
+		}
+		content, err := decrypt(dek, ciphertext, aad)
+		if err != nil {
+			return err
+		}
+
+		plain := map[string]interface{}{}
+		err = json.Unmarshal(content, &plain)
+		if err != nil {
+			return err
+		}
+		opened, err := record.WithData(plain)
+		if err != nil {
+			return err
+		}
+		opened.Sealed = ""
+		*record = opened
+	}
+	return nil
+}
+
+// Shred deletes the data key of a record, so its sealed fields can never
+// be decrypted again, even from backups of the records alone.
+func (c Cipher) Shred(tx *gorm.DB, tenant string, id uint) error {
+	return tx.Where("tenant = ? AND record_id = ?", tenant, id).Delete(&dataKey{}).Error
+}
+
+// Rewrap wraps every data key with the primary key of the key file,
+// returning how many were rewrapped.
+func (c Cipher) Rewrap(db *gorm.DB) (int, error) {
+	primary, err := c.keys.Primary()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		var keys []dataKey
+		result := db.Where("key_id <> ?", primary.ID).Limit(100).Find(&keys)
+		if result.Error != nil {
+			return count, result.Error
+		}
+		if len(keys) == 0 {
+			return count, nil
+		}
+
+		for _, key := range keys {
+			dek, err := c.unwrap(key)
+			if err != nil {
+				return count, err
+			}
+			wrapped, err := encrypt(primary.Secret, dek, recordAAD(key.Tenant, key.RecordID))
+			if err != nil {
+				return count, err
+			}
+			result := db.Model(&dataKey{}).
+				Where("tenant = ? AND record_id = ?", key.Tenant, key.RecordID).
+				Updates(map[string]interface{}{"key_id": primary.ID, "wrapped": wrapped})
+			if result.Error != nil {
+				return count, result.Error
+			}
+			count++
+		}
+	}
+}
+
+// SealPlaintext encrypts the fields of the versions that were written
+// in plaintext, before encryption or the field was configured, returning
+// how many versions were sealed. Every page of versions is sealed in a
+// maintenance transaction attributed to actor at the time of clock, see
+// model.Maintain.
+func (c Cipher) SealPlaintext(db *gorm.DB, clock clock.Clock, actor string) (int, error) {
+	count := 0
+	for offset := 0; ; offset += 100 {
+		var records []model.Record
+		result := db.Order("tenant, id, updated_at").Offset(offset).Limit(100).Find(&records)
+		if result.Error != nil {
+			return count, result.Error
+		}
+		if len(records) == 0 {
+			return count, nil
+		}
+
+		var plaintext []model.Record
+		for _, record := range records {
+			if c.hasPlaintext(record) {
+				plaintext = append(plaintext, record)
+			}
+		}
+		if len(plaintext) == 0 {
+			continue
+		}
+
+		entry := model.Maintenance{
+			Actor:       actor,
+			Operation:   model.MaintenanceEncryption,
+			Reason:      "encrypt the fields written in plaintext",
+			PerformedAt: clock.Now().Truncate(time.Second),
+		}
+		err := model.Maintain(db, &entry, func(tx *gorm.DB) (int, error) {
+			for _, record := range plaintext {
+				err := c.sealVersion(tx, record)
+				if err != nil {
+					return 0, err
+				}
+			}
+			return len(plaintext), nil
+		})
+		if err != nil {
+			return count, err
+		}
+		count += len(plaintext)
+	}
+}
+
+// sealVersion encrypts the fields of a version written in plaintext.
+func (c Cipher) sealVersion(tx *gorm.DB, record model.Record) error {
+	opened := record
+	err := c.Open(tx, &opened)
+	if err != nil {
+		return err
+	}
+	data := opened.GetData()
+	err = c.Seal(tx, record.Tenant, record.ID, data)
+	if err != nil {
+		return err
+	}
+
+	// UpdateColumns leaves updated_at, which dates the version, as is
+	sealed := map[string]interface{}{"sealed": data["sealed"]}
+	for _, field := range c.fields {
+		sealed[field] = nil
+	}
+	return tx.Model(&model.Record{}).
+		Where("tenant = ? AND id = ? AND updated_at = ?",
+			record.Tenant, record.ID, model.Timestamp(tx, record.UpdatedAt)).
+		UpdateColumns(sealed).Error
+}
+
+// hasPlaintext is true when a version has an encrypted field in its
+// plaintext columns.
+func (c Cipher) hasPlaintext(record model.Record) bool {
+	data := record.GetData()
+	for _, field := range c.fields {
+		if value, ok := data[field]; ok && !reflect.ValueOf(value).IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// dataKey returns the data key of a record, creating it when create is
+// true and it doesn't exist yet.
+//
+// The key is created before it is read, since a sqlite transaction that
+// starts with a read fails rather than waits to write.
+func (c Cipher) dataKey(db *gorm.DB, tenant string, id uint, create bool) ([]byte, error) {
+	if create {
+		err := c.createDataKey(db, tenant, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var key dataKey
+	result := db.Where("tenant = ? AND record_id = ?", tenant, id).Limit(1).Find(&key)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: %s/%d", ErrNoDataKey, tenant, id)
+	}
+	return c.unwrap(key)
+}
+
+// createDataKey generates a data key for the record, unless it has one.
+func (c Cipher) createDataKey(db *gorm.DB, tenant string, id uint) error {
+	primary, err := c.keys.Primary()
+	if err != nil {
+		return err
+	}
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return err
+	}
+	wrapped, err := encrypt(primary.Secret, dek, recordAAD(tenant, id))
+	if err != nil {
+		return err
+	}
+
+	key := dataKey{Tenant: tenant, RecordID: id, KeyID: primary.ID, Wrapped: wrapped}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&key).Error
+}
+
+func (c Cipher) unwrap(key dataKey) ([]byte, error) {
+	kek, err := c.keys.Key(key.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(kek.Secret, key.Wrapped, recordAAD(key.Tenant, key.RecordID))
+}
+
+// recordAAD binds ciphertexts to their record, so they can't be moved
+// to another one.
+func recordAAD(tenant string, id uint) []byte {
+	return []byte(fmt.Sprintf("%s/%d", tenant, id))
+}
+
+// encrypt seals plaintext with AES-256-GCM, prefixed by its nonce.
+func encrypt(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func decrypt(key []byte, ciphertext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
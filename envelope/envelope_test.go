@@ -0,0 +1,190 @@
+package envelope_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/envelope"
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"gorm.io/gorm"
+)
+
+var now = time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+
+func setup(t *testing.T) (*gorm.DB, string) {
+	dir := t.TempDir()
+	db, err := model.OpenDb(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migration.NewMigrator(db, migration.SQLite).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(dir, "keys.json")
+	if _, err := envelope.AddKey(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	return db, keyFile
+}
+
+// columns reads a version as stored, without decrypting it.
+func columns(t *testing.T, db *gorm.DB, id uint) map[string]interface{} {
+	t.Helper()
+	row := map[string]interface{}{}
+	err := db.Table("records").Where("id = ?", id).Order("updated_at desc").Limit(1).Take(&row).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestCipher(t *testing.T) {
+	db, keyFile := setup(t)
+	cipher := envelope.NewCipher(envelope.NewKeyring(keyFile), []string{"email", "dob"})
+	clock := clock.NewFake(now)
+	records := service.NewSQLiteRecordService(db, clock)
+	records.SetSealer(cipher)
+
+	ctx := context.Background()
+	created, err := records.CreateRecord(ctx, 1, map[string]interface{}{
+		"first_name": "Steve",
+		"email":      "steve@apple.com",
+		"dob":        "1955-02-24T00:00:00-07:00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Email != "steve@apple.com" || created.Dob.IsZero() {
+		t.Errorf("CreateRecord = %+v, want it decrypted", created)
+	}
+
+	row := columns(t, db, 1)
+	if row["first_name"] != "Steve" || row["email"] != nil || row["dob"] != nil ||
+		!strings.HasPrefix(row["sealed"].(string), "v1:") || strings.Contains(row["sealed"].(string), "steve") {
+		t.Errorf("stored %v, want email and dob sealed", row)
+	}
+
+	// the same email is no change, compared to the decrypted record
+	clock.Advance(time.Second)
+	updated, err := records.UpdateRecord(ctx, created, map[string]interface{}{"email": "steve@apple.com"})
+	if err != nil || !updated.UpdatedAt.Equal(created.UpdatedAt) {
+		t.Errorf("UpdateRecord with the same email = %+v, %v, want no new version", updated, err)
+	}
+	_, err = records.UpdateRecord(ctx, created, map[string]interface{}{"email": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := records.GetVersions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Email != "" || versions[1].Email != "steve@apple.com" ||
+		!versions[0].Dob.Equal(versions[1].Dob) {
+		t.Errorf("GetVersions = %+v, want the email deleted from the dob", versions)
+	}
+
+	// sealed fields can't be moved to another record
+	_, err = records.CreateRecord(ctx, 2, map[string]interface{}{"email": "jane@apple.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := model.Maintenance{Operation: "tamper", PerformedAt: now}
+	err = model.Maintain(db, &entry, func(tx *gorm.DB) (int, error) {
+		return 0, tx.Exec("UPDATE records SET sealed = (SELECT sealed FROM records WHERE id = 1 LIMIT 1) WHERE id = 2").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = records.GetRecord(ctx, 2)
+	if !errors.Is(err, envelope.ErrInvalidCiphertext) {
+		t.Errorf("GetRecord with the sealed fields of another record err = %v, want ErrInvalidCiphertext", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	db, keyFile := setup(t)
+	clock := clock.NewFake(now)
+
+	// versions written before encryption was enabled
+	plain := service.NewSQLiteRecordService(db, clock)
+	first, err := plain.CreateRecord(context.Background(), 1, map[string]interface{}{"first_name": "Steve", "city": "Cupertino"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	_, err = plain.UpdateRecord(context.Background(), first, map[string]interface{}{"city": "Palo Alto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher := envelope.NewCipher(envelope.NewKeyring(keyFile), model.Record{}.MutableFields())
+	records := service.NewSQLiteRecordService(db, clock)
+	records.SetSealer(cipher)
+	_, err = records.CreateRecord(context.Background(), 2, map[string]interface{}{"first_name": "Jane"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary, err := envelope.AddKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, err := cipher.Rewrap(db)
+	if err != nil || rewrapped != 1 {
+		t.Errorf("Rewrap = %d, %v, want 1 data key", rewrapped, err)
+	}
+	clock.Advance(time.Hour)
+	sealed, err := cipher.SealPlaintext(db, clock, "operator")
+	if err != nil || sealed != 2 {
+		t.Errorf("SealPlaintext = %d, %v, want 2 versions", sealed, err)
+	}
+	var entries []model.Maintenance
+	if err := db.Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].PerformedAt.Equal(clock.Now()) {
+		t.Errorf("maintenance = %+v, want one entry performed at %s", entries, clock.Now())
+	}
+	if row := columns(t, db, 1); row["first_name"] != nil || row["city"] != nil {
+		t.Errorf("stored %v, want the plaintext sealed", row)
+	}
+
+	// the previous keys are no longer needed
+	content, err := json.Marshal(map[string]interface{}{"keys": []envelope.Key{primary}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, content, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh := service.NewSQLiteRecordService(db, clock)
+	fresh.SetSealer(envelope.NewCipher(envelope.NewKeyring(keyFile), model.Record{}.MutableFields()))
+
+	versions, err := fresh.GetVersions(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].City != "Palo Alto" || versions[1].City != "Cupertino" || versions[1].FirstName != "Steve" {
+		t.Errorf("GetVersions = %+v, want both versions decrypted", versions)
+	}
+	if !versions[1].UpdatedAt.Equal(first.UpdatedAt) {
+		t.Errorf("sealed version updated at %v, want %v", versions[1].UpdatedAt, first.UpdatedAt)
+	}
+	jane, err := fresh.GetRecord(context.Background(), 2)
+	if err != nil || jane.FirstName != "Jane" {
+		t.Errorf("GetRecord = %+v, %v, want Jane", jane, err)
+	}
+}
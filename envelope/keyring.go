@@ -0,0 +1,159 @@
+// Package envelope encrypts the personal fields of records at rest with
+// envelope encryption: every record has its own data key (DEK), which
+// encrypts its versions, and is itself stored wrapped by a key
+// encryption key (KEK) of a local key file.
+//
+// Rotating the KEK only rewraps the data keys, and deleting the data key
+// of a record makes every version of it unreadable.
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrNoKeys = errors.New("the key file has no keys")
+var ErrUnknownKey = errors.New("unknown key encryption key")
+
+// keySize is the size of every key, for AES-256.
+const keySize = 32
+
+// Key is a key encryption key of the key file.
+type Key struct {
+	ID     string `json:"id"`
+	Secret []byte `json:"key"`
+}
+
+// keyFile is the json content of a key file, whose last key wraps the
+// new data keys.
+type keyFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// Keyring holds the keys of a key file. The file is read again when it
+// changes, so a running server picks up the keys added by a rotation.
+type Keyring struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	keys    []Key
+}
+
+// NewKeyring holds the keys of the key file at path, which is only read
+// when they are used, since it is created by the first rotation.
+func NewKeyring(path string) *Keyring {
+	return &Keyring{path: path}
+}
+
+// Primary returns the key wrapping the new data keys.
+func (k *Keyring) Primary() (Key, error) {
+	err := k.refresh()
+	if err != nil {
+		return Key{}, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.keys[len(k.keys)-1], nil
+}
+
+// Key returns the key of id.
+func (k *Keyring) Key(id string) (Key, error) {
+	err := k.refresh()
+	if err != nil {
+		return Key{}, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, key := range k.keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+	return Key{}, fmt.Errorf("%w %q", ErrUnknownKey, id)
+}
+
+// refresh reads the key file again when it was modified.
+func (k *Keyring) refresh() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if info.ModTime().Equal(k.modTime) && len(k.keys) > 0 {
+		return nil
+	}
+
+	keys, err := readKeyFile(k.path)
+	if err != nil {
+		return err
+	}
+	if len(keys.Keys) == 0 {
+		return fmt.Errorf("%s: %w", k.path, ErrNoKeys)
+	}
+	k.keys = keys.Keys
+	k.modTime = info.ModTime()
+	return nil
+}
+
+// AddKey generates a key and appends it to the key file at path, which
+// is created if it doesn't exist, making it the primary key.
+func AddKey(path string) (Key, error) {
+	keys, err := readKeyFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Key{}, err
+	}
+
+	id := make([]byte, 4)
+	secret := make([]byte, keySize)
+	if _, err := rand.Read(id); err != nil {
+		return Key{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	key := Key{ID: hex.EncodeToString(id), Secret: secret}
+	keys.Keys = append(keys.Keys, key)
+
+	content, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return Key{}, err
+	}
+
+	// replace the file at once, so servers never read half of it
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, content, 0o600)
+	if err != nil {
+		return Key{}, err
+	}
+	return key, os.Rename(tmp, path)
+}
+
+func readKeyFile(path string) (keyFile, error) {
+	keys := keyFile{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return keys, err
+	}
+
+	err = json.Unmarshal(content, &keys)
+	if err != nil {
+		return keys, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, key := range keys.Keys {
+		if key.ID == "" || len(key.Secret) != keySize {
+			return keys, fmt.Errorf("%s: key %q must have an id and %d bytes", path, key.ID, keySize)
+		}
+	}
+	return keys, nil
+}
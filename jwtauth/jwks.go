@@ -0,0 +1,176 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// jsonWebKey is a key of a JWKS, as described by RFC 7517 and 8037.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey is a signing key of the identity provider.
+type publicKey struct {
+	kid string
+	// alg restricts the algorithm of the tokens signed with the key,
+	// when the jwks sets it.
+	alg string
+	key crypto.PublicKey
+}
+
+// isURL is true for jwks served over http, which can be refreshed.
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// loadJWKS reads the signing keys of a jwks file or url. Keys of other
+// types or uses are skipped.
+func loadJWKS(ctx context.Context, client *http.Client, source string) ([]publicKey, error) {
+	var body []byte
+	var err error
+	if isURL(source) {
+		body, err = fetch(ctx, client, source)
+	} else {
+		body, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = json.Unmarshal(body, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	keys := []publicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", source, jwk.Kid, err)
+		}
+		if key != nil {
+			keys = append(keys, publicKey{jwk.Kid, jwk.Alg, key})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: %w", source, ErrNoKeys)
+	}
+	return keys, nil
+}
+
+func fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// publicKey decodes the key, or returns nil for unsupported key types.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() < 3 {
+			return nil, fmt.Errorf("rsa keys must have at least 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != size {
+			return nil, fmt.Errorf("invalid x")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != size {
+			return nil, fmt.Errorf("invalid y")
+		}
+		// ecdh checks that the point is on the curve
+		_, err = ecdhCurve.NewPublicKey(append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid x")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, nil
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
@@ -0,0 +1,279 @@
+// Package jwtauth authenticates the JSON Web Tokens of an identity
+// provider, against the keys of its JWKS.
+//
+// Tokens must be signed with RS256, RS384, RS512, ES256, ES384 or EdDSA,
+// expire, and carry a subject. Their roles claim lists the roles of the
+// subject, and roles named after a scope grant it, as do the entries of
+// the OAuth scope claim.
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+)
+
+var ErrNoKeys = errors.New("jwks has no signing keys")
+
+// algorithms maps the supported algorithms to their hash.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"EdDSA": 0,
+}
+
+// Verifier implements auth.Authenticator for the tokens signed by the
+// keys of a jwks.
+type Verifier struct {
+	source string
+	clock  clock.Clock
+	client *http.Client
+
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+
+	// Leeway tolerates that much clock skew with the identity provider.
+	Leeway time.Duration
+
+	// RefreshInterval is the least time between two fetches of a jwks
+	// url, which is fetched again when a token is signed by an unknown
+	// key, for the keys to be rotated.
+	RefreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      []publicKey
+	fetchedAt time.Time
+}
+
+// NewVerifier loads the jwks of source, which is either a file or an
+// http url.
+func NewVerifier(source string, clock clock.Clock) (*Verifier, error) {
+	v := &Verifier{
+		source:          source,
+		clock:           clock,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		Leeway:          time.Minute,
+		RefreshInterval: time.Minute,
+	}
+
+	keys, err := loadJWKS(context.Background(), v.client, source)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = clock.Now()
+	return v, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Roles     []string `json:"roles"`
+	Scope     string   `json:"scope"`
+	Tenant    string   `json:"tenant"`
+}
+
+// audience is either a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	err := json.Unmarshal(data, &list)
+	*a = list
+	return err
+}
+
+func (a audience) contains(value string) bool {
+	for _, aud := range a {
+		if aud == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate checks the signature and claims of a token, and returns
+// its subject. Invalid tokens fail with auth.ErrUnauthenticated.
+func (v *Verifier) Authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return auth.Principal{}, invalid("malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return auth.Principal{}, invalid("malformed header")
+	}
+	hash, ok := algorithms[h.Alg]
+	if !ok {
+		return auth.Principal{}, invalid("unsupported algorithm %q", h.Alg)
+	}
+
+	key, ok := v.key(ctx, h.Kid)
+	if !ok {
+		return auth.Principal{}, invalid("unknown key %q", h.Kid)
+	}
+	if key.alg != "" && key.alg != h.Alg {
+		return auth.Principal{}, invalid("key %q is not for %s", h.Kid, h.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !verify(h.Alg, hash, key.key, []byte(parts[0]+"."+parts[1]), signature) {
+		return auth.Principal{}, invalid("invalid signature")
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return auth.Principal{}, invalid("malformed claims")
+	}
+	if err := v.validate(c); err != nil {
+		return auth.Principal{}, err
+	}
+
+	principal := auth.Principal{Subject: c.Subject, Scopes: []string{}, Roles: c.Roles, Tenant: c.Tenant}
+	for _, scope := range append(append([]string{}, c.Roles...), strings.Fields(c.Scope)...) {
+		if auth.IsScope(scope) {
+			principal.Scopes = append(principal.Scopes, scope)
+		}
+	}
+	return principal, nil
+}
+
+func (v *Verifier) validate(c claims) error {
+	now := v.clock.Now()
+	if c.Subject == "" {
+		return invalid("no subject")
+	}
+	if c.ExpiresAt == nil {
+		return invalid("no expiry")
+	}
+	if now.After(unix(*c.ExpiresAt).Add(v.Leeway)) {
+		return invalid("expired")
+	}
+	if c.NotBefore != nil && now.Add(v.Leeway).Before(unix(*c.NotBefore)) {
+		return invalid("not valid yet")
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return invalid("unexpected issuer %q", c.Issuer)
+	}
+	if v.Audience != "" && !c.Audience.contains(v.Audience) {
+		return invalid("unexpected audience %v", []string(c.Audience))
+	}
+	if c.Tenant != "" && !tenant.IsValid(c.Tenant) {
+		return invalid("invalid tenant %q", c.Tenant)
+	}
+	return nil
+}
+
+// key finds the key of kid, fetching the jwks url again when it is
+// unknown. A token without kid is accepted when there is a single key.
+func (v *Verifier) key(ctx context.Context, kid string) (publicKey, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.find(kid)
+	if ok || !isURL(v.source) || v.clock.Now().Sub(v.fetchedAt) < v.RefreshInterval {
+		return key, ok
+	}
+
+	keys, err := loadJWKS(ctx, v.client, v.source)
+	v.fetchedAt = v.clock.Now()
+	if err != nil {
+		logging.LogError(err)
+		return publicKey{}, false
+	}
+	v.keys = keys
+	return v.find(kid)
+}
+
+func (v *Verifier) find(kid string) (publicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		return v.keys[0], true
+	}
+	for _, key := range v.keys {
+		if key.kid == kid {
+			return key, true
+		}
+	}
+	return publicKey{}, false
+}
+
+func verify(alg string, hash crypto.Hash, key crypto.PublicKey, signed []byte, signature []byte) bool {
+	if alg == "EdDSA" {
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, signed, signature)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") &&
+			rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// the signature is r and s, each the size of the curve
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || alg[2:] != fmt.Sprint(pub.Curve.Params().BitSize) || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, v)
+}
+
+func unix(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// invalid wraps auth.ErrUnauthenticated with the reason a token was
+// rejected.
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{auth.ErrUnauthenticated}, args...)...)
+}
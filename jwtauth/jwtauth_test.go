@@ -0,0 +1,256 @@
+package jwtauth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/jwtauth"
+)
+
+var now = time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+
+// keys are generated once for the whole package.
+type keys struct {
+	rsa     *rsa.PrivateKey
+	ecdsa   *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+var (
+	generateOnce sync.Once
+	generated    keys
+)
+
+func generate(t *testing.T) keys {
+	generateOnce.Do(func() {
+		var err error
+		generated.rsa, err = rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		generated.ecdsa, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, generated.ed25519, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	return generated
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwks publishes the public halves of k.
+func (k keys) jwks() []byte {
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "alg": "RS256",
+			"n": encode(k.rsa.N.Bytes()), "e": encode(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": encode(k.ecdsa.X.FillBytes(make([]byte, 32))), "y": encode(k.ecdsa.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": encode(k.ed25519.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}})
+	return jwks
+}
+
+// sign makes a token of claims, signed by the key of kid.
+func (k keys) sign(t *testing.T, alg string, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch kid {
+	case "rsa":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "ec":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ecdsa, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case "ed":
+		signature = ed25519.Sign(k.ed25519, []byte(signed))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + encode(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":    "steve",
+		"iss":    "https://id.internal",
+		"aud":    []string{"timetravel", "billing"},
+		"exp":    now.Add(time.Hour).Unix(),
+		"roles":  []string{"read", "support"},
+		"scope":  "history",
+		"tenant": "acme",
+	}
+}
+
+func newVerifier(t *testing.T, k keys) *jwtauth.Verifier {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err := os.WriteFile(path, k.jwks(), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := jwtauth.NewVerifier(path, clock.NewFake(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Issuer = "https://id.internal"
+	v.Audience = "timetravel"
+	return v
+}
+
+func TestVerifier(t *testing.T) {
+	k := generate(t)
+	v := newVerifier(t, k)
+
+	for _, signer := range []struct{ alg, kid string }{{"RS256", "rsa"}, {"ES256", "ec"}, {"EdDSA", "ed"}} {
+		principal, err := v.Authenticate(context.Background(), k.sign(t, signer.alg, signer.kid, validClaims()))
+		if err != nil {
+			t.Fatalf("%s: %v", signer.alg, err)
+		}
+		want := auth.Principal{
+			Subject: "steve",
+			Scopes:  []string{"read", "history"},
+			Roles:   []string{"read", "support"},
+			Tenant:  "acme",
+		}
+		if !reflect.DeepEqual(principal, want) {
+			t.Errorf("%s: principal = %+v, want %+v", signer.alg, principal, want)
+		}
+	}
+}
+
+func TestVerifierRejects(t *testing.T) {
+	k := generate(t)
+	v := newVerifier(t, k)
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	valid := k.sign(t, "RS256", "rsa", validClaims())
+	header, _, _ := strings.Cut(valid, ".")
+	tampered := k.sign(t, "RS256", "rsa", with("sub", "jane"))
+	_, payload, _ := strings.Cut(tampered, ".")
+	payload, _, _ = strings.Cut(payload, ".")
+	none := encode([]byte(`{"alg":"none","kid":"rsa"}`)) + "." + payload + "."
+
+	tests := map[string]string{
+		"expired":          k.sign(t, "RS256", "rsa", with("exp", now.Add(-2*time.Minute).Unix())),
+		"without expiry":   k.sign(t, "RS256", "rsa", with("exp", nil)),
+		"not valid yet":    k.sign(t, "RS256", "rsa", with("nbf", now.Add(time.Hour).Unix())),
+		"without subject":  k.sign(t, "RS256", "rsa", with("sub", nil)),
+		"other issuer":     k.sign(t, "RS256", "rsa", with("iss", "https://evil.internal")),
+		"other audience":   k.sign(t, "RS256", "rsa", with("aud", "billing")),
+		"unknown key":      k.sign(t, "RS256", "other", validClaims()),
+		"wrong algorithm":  k.sign(t, "RS384", "rsa", validClaims()),
+		"tampered":         header + "." + payload + "." + strings.Split(valid, ".")[2],
+		"unsigned":         none,
+		"malformed":        "not.a.token",
+		"api key":          "tt_Xk2f0a",
+		"encryption key":   k.sign(t, "RS256", "encryption", validClaims()),
+		"mismatched curve": k.sign(t, "ES384", "ec", validClaims()),
+		"invalid tenant":   k.sign(t, "RS256", "rsa", with("tenant", "../acme")),
+	}
+	for name, token := range tests {
+		_, err := v.Authenticate(context.Background(), token)
+		if !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Errorf("%s: err = %v, want ErrUnauthenticated", name, err)
+		}
+	}
+
+	// the leeway tolerates a little clock skew
+	_, err := v.Authenticate(context.Background(), k.sign(t, "RS256", "rsa", with("exp", now.Add(-30*time.Second).Unix())))
+	if err != nil {
+		t.Errorf("within leeway: %v", err)
+	}
+}
+
+func TestVerifierRefreshesURL(t *testing.T) {
+	k := generate(t)
+
+	var mu sync.Mutex
+	jwks := []byte(`{"keys":[{"kty":"OKP","kid":"old","crv":"Ed25519","x":"` +
+		encode(make([]byte, ed25519.PublicKeySize)) + `"}]}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(jwks)
+	}))
+	t.Cleanup(srv.Close)
+
+	clock := clock.NewFake(now)
+	v, err := jwtauth.NewVerifier(srv.URL, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the identity provider rotates its keys
+	mu.Lock()
+	jwks = k.jwks()
+	mu.Unlock()
+	token := k.sign(t, "EdDSA", "ed", validClaims())
+
+	_, err = v.Authenticate(context.Background(), token)
+	if !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("before the refresh interval: err = %v, want ErrUnauthenticated", err)
+	}
+
+	clock.Advance(v.RefreshInterval)
+	_, err = v.Authenticate(context.Background(), token)
+	if err != nil {
+		t.Errorf("after the refresh interval: %v", err)
+	}
+}
+
+func TestNewVerifierWithoutKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err := os.WriteFile(path, []byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = jwtauth.NewVerifier(path, clock.Real())
+	if !errors.Is(err, jwtauth.ErrNoKeys) {
+		t.Errorf("err = %v, want ErrNoKeys", err)
+	}
+}
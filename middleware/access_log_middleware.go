@@ -41,3 +41,9 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	rw.contentLength += len(b)
 	return rw.ResponseWriter.Write(b)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer, to
+// flush streamed responses.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
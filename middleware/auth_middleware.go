@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+)
+
+// AuthMiddleware authenticates the token of requests, sent as
+// `Authorization: Bearer <token>` or `X-API-Key: <token>`, and responds
+// with a 401 when it is invalid. Requests without a token go through
+// unauthenticated, for RequireScope to reject them on protected routes.
+//
+// A nil authenticator disables authentication: every request is made
+// by auth.Anonymous.
+func AuthMiddleware(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authenticator == nil {
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), auth.Anonymous)))
+				return
+			}
+
+			token := auth.BearerToken(r.Header.Get("Authorization"))
+			if token == "" {
+				token = r.Header.Get("X-API-Key")
+			}
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r.Context(), token)
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				writeUnauthorized(w, r, "unauthorized; invalid credentials")
+				return
+			}
+			if err != nil {
+				logging.LogError(err)
+				err := response.WriteErrorFor(
+					w,
+					r,
+					response.ErrInternal.Error(),
+					http.StatusInternalServerError,
+				)
+				logging.LogError(err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// RequireScope responds with a 401 to unauthenticated requests, and
+// with a 403 to those whose principal lacks one of scopes. Without
+// scopes, the route is public.
+func RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(scopes) == 0 || scopes[0] == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if Authorize(w, r, scopes...) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Authorize checks that the principal of a request was granted scopes,
+// for handlers whose requirements depend on the request. Otherwise it
+// responds like RequireScope and returns false.
+func Authorize(w http.ResponseWriter, r *http.Request, scopes ...string) bool {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		writeUnauthorized(w, r, "unauthorized; credentials are required")
+		return false
+	}
+	if !principal.Can(scopes...) {
+		err := response.WriteErrorFor(
+			w,
+			r,
+			"forbidden; requires the "+strings.Join(scopes, " and ")+" scope",
+			http.StatusForbidden,
+		)
+		logging.LogError(err)
+		return false
+	}
+	return true
+}
+
+// AuthorizeFields checks that the principal of a request can write the
+// fields of a payload. Otherwise it responds with a 403 listing them and
+// returns false.
+func AuthorizeFields(w http.ResponseWriter, r *http.Request, data map[string]interface{}) bool {
+	principal, _ := auth.FromContext(r.Context())
+	fields := principal.Unwritable(data)
+	if len(fields) == 0 {
+		return true
+	}
+
+	err := response.WriteErrorFor(
+		w,
+		r,
+		auth.ForbiddenFields(fields),
+		http.StatusForbidden,
+	)
+	logging.LogError(err)
+	return false
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	err := response.WriteErrorFor(w, r, message, http.StatusUnauthorized)
+	logging.LogError(err)
+}
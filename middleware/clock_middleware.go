@@ -0,0 +1,15 @@
+package middleware
+
+import "net/http"
+
+// ClockMiddleware sets the X-Timetravel-Clock header of every response
+// to clock, which describes how the server clock was changed, so that
+// the timestamps of the responses aren't mistaken for real ones.
+func ClockMiddleware(clock string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Timetravel-Clock", clock)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+)
+
+// JSONAPIMiddleware negotiates JSON:API responses for requests that
+// accept application/vnd.api+json, leaving other responses untouched.
+//
+// Bodies sent as application/vnd.api+json are resource documents, whose
+// attributes are unwrapped into the plain json object handlers expect,
+// whatever the response is negotiated as.
+func JSONAPIMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if acceptsJSONAPI(r) {
+			r = r.WithContext(response.WithJSONAPI(r.Context()))
+		}
+
+		if isJSONAPIBody(r) {
+			attributes, err := unwrapAttributes(r.Body)
+			if err != nil {
+				err := response.WriteErrorFor(
+					w,
+					r,
+					"invalid input; body must be a JSON:API resource document",
+					http.StatusBadRequest,
+				)
+				logging.LogError(err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(attributes))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptsJSONAPI is true when the Accept header lists the JSON:API media
+// type without parameters, as required by the specification.
+func acceptsJSONAPI(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			if strings.TrimSpace(mediaRange) == response.JSONAPIContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isJSONAPIBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == response.JSONAPIContentType
+}
+
+func unwrapAttributes(body io.Reader) ([]byte, error) {
+	var document struct {
+		Data *struct {
+			Type       string          `json:"type"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+	err := json.NewDecoder(body).Decode(&document)
+	if err != nil {
+		return nil, err
+	}
+	if document.Data == nil || document.Data.Attributes == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return document.Data.Attributes, nil
+}
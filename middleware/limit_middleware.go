@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/limit"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+)
+
+// LimitMiddleware responds with a 429 and a Retry-After header to the
+// callers making requests to the routes of group faster than the rate
+// of limits, and with a 413 to requests whose body is larger than
+// limits.MaxBodyBytes.
+//
+// Callers are told apart by their principal, e.g. their api key, or by
+// their ip when they are anonymous, see limit.Limiter.
+func LimitMiddleware(group string, limits limit.Limits, clock clock.Clock) func(http.Handler) http.Handler {
+	limiter := limit.NewLimiter(limits, clock)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wait, ok := limiter.Take(callerOf(r))
+			if !ok {
+				limit.Rejections.Add(group+".rate_limited", 1)
+				writeTooManyRequests(w, r, wait)
+				return
+			}
+
+			if limits.MaxBodyBytes > 0 && r.Body != nil {
+				err := limitBody(r, limits.MaxBodyBytes)
+				if errors.Is(err, errBodyTooLarge) {
+					limit.Rejections.Add(group+".body_too_large", 1)
+					err := response.WriteErrorFor(
+						w,
+						r,
+						fmt.Sprintf("request body too large; the limit is %d bytes", limits.MaxBodyBytes),
+						http.StatusRequestEntityTooLarge,
+					)
+					logging.LogError(err)
+					return
+				}
+				if err != nil {
+					writeInvalid(w, r, "invalid input; could not read the body")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AuthFailureLimitMiddleware limits how often every ip can fail to
+// authenticate, so that guessing tokens is throttled. Requests are
+// turned away before they are authenticated once the bucket of their ip
+// is empty, and only the responses which turn out to be a 401 take a
+// token from it, so that any number of requests authenticating fine can
+// be in flight.
+func AuthFailureLimitMiddleware(failures *limit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := ipOf(r)
+			wait, ok := failures.Check(ip)
+			if !ok {
+				limit.Rejections.Add("auth.rate_limited", 1)
+				writeTooManyRequests(w, r, wait)
+				return
+			}
+
+			settled := &settlingWriter{ResponseWriter: w, settle: func(status int) {
+				if status == http.StatusUnauthorized {
+					failures.Charge(ip)
+				}
+			}}
+			next.ServeHTTP(settled, r)
+			settled.settleOnce(http.StatusOK)
+		})
+	}
+}
+
+// settlingWriter calls settle with the status of the response once it
+// is known.
+type settlingWriter struct {
+	http.ResponseWriter
+	settle  func(status int)
+	settled bool
+}
+
+func (w *settlingWriter) settleOnce(status int) {
+	if !w.settled {
+		w.settled = true
+		w.settle(status)
+	}
+}
+
+func (w *settlingWriter) WriteHeader(status int) {
+	w.settleOnce(status)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *settlingWriter) Write(b []byte) (int, error) {
+	w.settleOnce(http.StatusOK)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, to
+// flush streamed responses.
+func (w *settlingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writeTooManyRequests responds with a 429 telling when to retry.
+func writeTooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	seconds := limit.RetryAfter(wait)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	err := response.WriteErrorFor(
+		w,
+		r,
+		fmt.Sprintf("too many requests; retry after %ds", seconds),
+		http.StatusTooManyRequests,
+	)
+	logging.LogError(err)
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitBody reads the body of r up front, so that handlers never see
+// more than max bytes, and requests without a length are rejected the
+// same way as the others.
+func limitBody(r *http.Request, max int64) error {
+	if r.ContentLength > max {
+		return errBodyTooLarge
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > max {
+		return errBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// MetricsHandler serves the counters of the middlewares as json.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, err := fmt.Fprintf(w, "{\"rejections\": %s}\n", limit.Rejections.String())
+	logging.LogError(err)
+}
+
+// callerOf identifies the caller of a request for its rate limit: its
+// principal, or its ip when it is anonymous.
+func callerOf(r *http.Request) string {
+	principal, ok := auth.FromContext(r.Context())
+	if ok && principal.Subject != auth.Anonymous.Subject {
+		return "principal:" + principal.Tenant + "/" + principal.Subject
+	}
+	return ipOf(r)
+}
+
+// ipOf identifies the caller of a request by the ip of its connection.
+func ipOf(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
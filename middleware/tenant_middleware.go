@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+)
+
+// TenantMiddleware resolves the tenant of requests, from their principal
+// or the `X-Tenant-ID` header, and responds with a 403 when a principal
+// bound to a tenant names another one, or one which isn't an admin names
+// any.
+func TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		t, err := tenant.Resolve(principal, r.Header.Get(tenant.Header))
+		if err != nil {
+			status := http.StatusBadRequest
+			if tenant.IsForbidden(err) {
+				status = http.StatusForbidden
+			}
+			err := response.WriteErrorFor(
+				w,
+				r,
+				err.Error(),
+				status,
+			)
+			logging.LogError(err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), t)))
+	})
+}
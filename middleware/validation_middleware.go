@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rainbowmga/timetravel/api/openapi"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/response"
+)
+
+type validatedKey struct{}
+
+// validated holds the parsed parameters and body of a request.
+type validated struct {
+	params map[string]interface{}
+	body   map[string]interface{}
+}
+
+// ValidationMiddleware validates the path parameters, query parameters
+// and json body of requests against the OpenAPI description of route,
+// and responds with a 400 when they don't match it.
+//
+// Handlers read the parsed values with PathID, QueryID, QueryTime and
+// Body.
+func ValidationMiddleware(route openapi.Route) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v := validated{params: make(map[string]interface{})}
+
+			for _, param := range route.Parameters {
+				var raw string
+				var present bool
+				switch param.In {
+				case "path":
+					raw, present = mux.Vars(r)[param.Name]
+				case "query":
+					raw = r.URL.Query().Get(param.Name)
+					present = raw != ""
+				}
+
+				if !present {
+					if param.Required {
+						writeInvalid(w, r, "invalid "+param.Name+"; "+param.Name+" is required")
+						return
+					}
+					continue
+				}
+
+				value, err := param.Parse(raw)
+				if err != nil {
+					writeValidationError(w, r, err)
+					return
+				}
+				v.params[param.Name] = value
+			}
+
+			if route.RequestBody != nil {
+				var body interface{}
+				err := json.NewDecoder(r.Body).Decode(&body)
+				if err != nil {
+					writeInvalid(w, r, "invalid input; could not parse json")
+					return
+				}
+
+				err = route.RequestBody.ValidateBody(body)
+				if err != nil {
+					writeValidationError(w, r, err)
+					return
+				}
+				v.body, _ = body.(map[string]interface{})
+			}
+
+			ctx := context.WithValue(r.Context(), validatedKey{}, v)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// PathID returns the validated `id` path parameter.
+func PathID(r *http.Request) uint {
+	id, _ := validatedFrom(r).params["id"].(int64)
+	return uint(id)
+}
+
+// QueryID returns the validated `id` query parameter, and whether it
+// was present.
+func QueryID(r *http.Request) (uint, bool) {
+	id, ok := validatedFrom(r).params["id"].(int64)
+	return uint(id), ok
+}
+
+// QueryTime returns a validated date-time query parameter, and whether
+// it was present.
+func QueryTime(r *http.Request, name string) (time.Time, bool) {
+	value, ok := validatedFrom(r).params[name].(time.Time)
+	return value, ok
+}
+
+// Body returns the validated json body.
+func Body(r *http.Request) map[string]interface{} {
+	return validatedFrom(r).body
+}
+
+func validatedFrom(r *http.Request) validated {
+	v, _ := r.Context().Value(validatedKey{}).(validated)
+	return v
+}
+
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *openapi.ValidationError
+	if errors.As(err, &validationErr) {
+		writeInvalid(w, r, validationErr.Message)
+		return
+	}
+	writeInvalid(w, r, err.Error())
+}
+
+func writeInvalid(w http.ResponseWriter, r *http.Request, message string) {
+	err := response.WriteErrorFor(w, r, message, http.StatusBadRequest)
+	logging.LogError(err)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rs/zerolog/log"
+)
+
+const migrateUsage = "usage: timetravel migrate up|down|status"
+
+// runMigrate implements `timetravel migrate up|down|status`.
+func runMigrate(migrator *migration.Migrator, args []string) {
+	if len(args) != 1 {
+		log.Fatal().Msg(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			log.Info().Msgf("applied %s", m)
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("migration failed")
+		}
+		if len(applied) == 0 {
+			log.Info().Msg("no pending migrations")
+		}
+	case "down":
+		m, err := migrator.Down()
+		if err != nil {
+			log.Fatal().Err(err).Msg("rollback failed")
+		}
+		log.Info().Msgf("rolled back %s", m)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not read migration status")
+		}
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Printf("applied  %s  %s\n", status.Migration, status.AppliedAt.Format(time.RFC3339))
+			} else {
+				fmt.Printf("pending  %s\n", status.Migration)
+			}
+		}
+	default:
+		log.Fatal().Msg(migrateUsage)
+	}
+}
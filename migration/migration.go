@@ -0,0 +1,203 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+var ErrUnknownMigration = errors.New("unknown migration applied to database")
+var ErrNothingToRollback = errors.New("no applied migrations to roll back")
+
+// Migration is a single, ordered change to the database schema.
+//
+// Up and Down are lists of statements that are executed in order
+// inside a transaction. Once a migration has been applied, its Up
+// statements must never change; edit the schema with a new migration.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// Checksum is the sha256 of the Up statements.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(strings.Join(m.Up, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// SchemaMigration is a row of the schema_migrations table.
+type SchemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status describes whether a known migration has been applied.
+type Status struct {
+	Migration Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back migrations, tracking them in the
+// schema_migrations table.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{db, migrations}
+}
+
+// Up applies all pending migrations in order, and returns them.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, migration := range pending {
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			for _, statement := range migration.Up {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum(),
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("%s: %w", migration, err)
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the most recently applied migration, and returns it.
+func (m *Migrator) Down() (Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+
+		migration := statuses[i].Migration
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			for _, statement := range migration.Down {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return Migration{}, fmt.Errorf("%s: %w", migration, err)
+		}
+		return migration, nil
+	}
+
+	return Migration{}, ErrNothingToRollback
+}
+
+// Pending returns the migrations that haven't been applied yet.
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Status returns every known migration in order along with whether
+// it has been applied. It fails if an applied migration is unknown
+// or its checksum no longer matches.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[uint]bool)
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		known[migration.Version] = true
+		statuses[i].Migration = migration
+
+		row, ok := applied[migration.Version]
+		if !ok {
+			continue
+		}
+		if row.Checksum != migration.Checksum() {
+			return nil, fmt.Errorf("%s: %w", migration, ErrChecksumMismatch)
+		}
+		statuses[i].Applied = true
+		statuses[i].AppliedAt = row.AppliedAt
+	}
+
+	for version, row := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%04d_%s: %w", version, row.Name, ErrUnknownMigration)
+		}
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) applied() (map[uint]SchemaMigration, error) {
+	err := m.db.Exec(
+		"CREATE TABLE IF NOT EXISTS schema_migrations (" +
+			"version bigint PRIMARY KEY, " +
+			"name text NOT NULL, " +
+			"checksum text NOT NULL, " +
+			"applied_at timestamp NOT NULL)",
+	).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	err = m.db.Order("version").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[uint]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
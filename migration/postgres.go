@@ -0,0 +1,209 @@
+package migration
+
+// Postgres lists the migrations of the postgres database in order.
+var Postgres = []Migration{
+	{
+		Version: 1,
+		Name:    "create_records",
+		Up: []string{
+			"CREATE TABLE records (" +
+				"id bigint NOT NULL," +
+				"created_at timestamptz NOT NULL," +
+				"updated_at timestamptz NOT NULL," +
+				"deleted_at timestamptz," +
+				"first_name text," +
+				"middle_name text," +
+				"last_name text," +
+				"email text," +
+				"dob timestamptz," +
+				"phone text," +
+				"street text," +
+				"city text," +
+				"state text," +
+				"zip text," +
+				"country text," +
+				"PRIMARY KEY (id, updated_at))",
+			"CREATE INDEX idx_records_deleted_at ON records (deleted_at)",
+		},
+		Down: []string{
+			"DROP TABLE records",
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_webhooks",
+		Up: []string{
+			"CREATE TABLE webhook_subscriptions (" +
+				"id bigserial PRIMARY KEY," +
+				"url text NOT NULL," +
+				"secret text NOT NULL," +
+				"fields text NOT NULL DEFAULT ''," +
+				"created_at timestamptz NOT NULL)",
+			"CREATE TABLE webhook_deliveries (" +
+				"id bigserial PRIMARY KEY," +
+				"subscription_id bigint NOT NULL," +
+				"record_id bigint NOT NULL," +
+				"event text NOT NULL," +
+				"version_at timestamptz NOT NULL," +
+				"previous_at timestamptz," +
+				"changed_fields text NOT NULL DEFAULT ''," +
+				"status text NOT NULL," +
+				"attempts integer NOT NULL DEFAULT 0," +
+				"last_error text NOT NULL DEFAULT ''," +
+				"next_attempt_at timestamptz NOT NULL," +
+				"created_at timestamptz NOT NULL)",
+			"CREATE INDEX idx_webhook_deliveries_status ON webhook_deliveries (status, next_attempt_at)",
+		},
+		Down: []string{
+			"DROP TABLE webhook_deliveries",
+			"DROP TABLE webhook_subscriptions",
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_api_keys",
+		Up: []string{
+			"CREATE TABLE api_keys (" +
+				"id bigserial PRIMARY KEY," +
+				"name text NOT NULL," +
+				"prefix text NOT NULL," +
+				"hash text NOT NULL UNIQUE," +
+				"scopes text NOT NULL," +
+				"created_at timestamptz NOT NULL," +
+				"revoked_at timestamptz)",
+		},
+		Down: []string{
+			"DROP TABLE api_keys",
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_records_actor",
+		Up: []string{
+			"ALTER TABLE records ADD COLUMN actor text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE records DROP COLUMN actor",
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_tenants",
+		Up: []string{
+			"ALTER TABLE records ADD COLUMN tenant text NOT NULL DEFAULT 'default'",
+			"ALTER TABLE records DROP CONSTRAINT records_pkey",
+			"ALTER TABLE records ADD PRIMARY KEY (tenant, id, updated_at)",
+			"ALTER TABLE webhook_subscriptions ADD COLUMN tenant text NOT NULL DEFAULT 'default'",
+			"ALTER TABLE api_keys ADD COLUMN tenant text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE api_keys DROP COLUMN tenant",
+			"ALTER TABLE webhook_subscriptions DROP COLUMN tenant",
+			"DELETE FROM records WHERE tenant <> 'default'",
+			"ALTER TABLE records DROP CONSTRAINT records_pkey",
+			"ALTER TABLE records DROP COLUMN tenant",
+			"ALTER TABLE records ADD PRIMARY KEY (id, updated_at)",
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_record_keys",
+		Up: []string{
+			"ALTER TABLE records ADD COLUMN sealed text NOT NULL DEFAULT ''",
+			"CREATE TABLE record_keys (" +
+				"tenant text NOT NULL," +
+				"record_id bigint NOT NULL," +
+				"key_id text NOT NULL," +
+				"wrapped bytea NOT NULL," +
+				"created_at timestamptz NOT NULL," +
+				"PRIMARY KEY (tenant, record_id))",
+		},
+		Down: []string{
+			"DROP TABLE record_keys",
+			"ALTER TABLE records DROP COLUMN sealed",
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_erasures",
+		Up: []string{
+			"ALTER TABLE records ADD COLUMN erased_at timestamptz",
+			"CREATE TABLE erasures (" +
+				"id bigserial PRIMARY KEY," +
+				"tenant text NOT NULL," +
+				"record_id bigint NOT NULL," +
+				"actor text NOT NULL," +
+				"reason text NOT NULL," +
+				"versions integer NOT NULL," +
+				"erased_at timestamptz NOT NULL)",
+			"CREATE INDEX idx_erasures_record ON erasures (tenant, record_id)",
+		},
+		Down: []string{
+			"DROP TABLE erasures",
+			"ALTER TABLE records DROP COLUMN erased_at",
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_version_hashes",
+		Up: []string{
+			"ALTER TABLE records ADD COLUMN digest text NOT NULL DEFAULT ''",
+			"ALTER TABLE records ADD COLUMN hash text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE records DROP COLUMN hash",
+			"ALTER TABLE records DROP COLUMN digest",
+		},
+	},
+	{
+		// timetravel.maintenance is only on within the transactions of
+		// model.Maintain, which set it locally
+		Version: 9,
+		Name:    "append_only_records",
+		Up: []string{
+			"CREATE TABLE maintenance (" +
+				"id bigserial PRIMARY KEY," +
+				"tenant text NOT NULL," +
+				"record_id bigint NOT NULL," +
+				"actor text NOT NULL," +
+				"operation text NOT NULL," +
+				"reason text NOT NULL," +
+				"versions integer NOT NULL DEFAULT 0," +
+				"performed_at timestamptz NOT NULL)",
+			"CREATE INDEX idx_maintenance_record ON maintenance (tenant, record_id)",
+			"CREATE FUNCTION records_append_only() RETURNS trigger AS $$ BEGIN " +
+				"IF current_setting('timetravel.maintenance', true) IS DISTINCT FROM 'on' THEN " +
+				"RAISE EXCEPTION 'versions of records are append-only'; END IF; " +
+				"IF TG_OP = 'DELETE' THEN RETURN OLD; END IF; RETURN NEW; " +
+				"END $$ LANGUAGE plpgsql",
+			"CREATE TRIGGER records_append_only BEFORE UPDATE OR DELETE ON records " +
+				"FOR EACH ROW EXECUTE FUNCTION records_append_only()",
+			"CREATE FUNCTION maintenance_append_only() RETURNS trigger AS $$ BEGIN " +
+				"IF TG_OP <> 'INSERT' THEN " +
+				"RAISE EXCEPTION 'the maintenance log is append-only'; END IF; " +
+				"IF current_setting('timetravel.maintenance', true) IS DISTINCT FROM 'on' THEN " +
+				"RAISE EXCEPTION 'the maintenance log is only written by maintenance'; END IF; " +
+				"RETURN NEW; " +
+				"END $$ LANGUAGE plpgsql",
+			"CREATE TRIGGER maintenance_append_only BEFORE INSERT OR UPDATE OR DELETE ON maintenance " +
+				"FOR EACH ROW EXECUTE FUNCTION maintenance_append_only()",
+		},
+		Down: []string{
+			"DROP TRIGGER maintenance_append_only ON maintenance",
+			"DROP FUNCTION maintenance_append_only",
+			"DROP TRIGGER records_append_only ON records",
+			"DROP FUNCTION records_append_only",
+			"DROP TABLE maintenance",
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add_api_key_roles",
+		Up: []string{
+			"ALTER TABLE api_keys ADD COLUMN roles text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE api_keys DROP COLUMN roles",
+		},
+	},
+}
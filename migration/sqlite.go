@@ -0,0 +1,251 @@
+package migration
+
+// SQLite lists the migrations of the sqlite database in order.
+//
+// The first migration matches the schema previously created by GORM's
+// AutoMigrate, so existing databases can adopt it without data loss.
+var SQLite = []Migration{
+	{
+		Version: 1,
+		Name:    "create_records",
+		Up: []string{
+			"CREATE TABLE IF NOT EXISTS `records` (" +
+				"`id` integer," +
+				"`created_at` datetime," +
+				"`updated_at` datetime," +
+				"`deleted_at` datetime," +
+				"`first_name` text," +
+				"`middle_name` text," +
+				"`last_name` text," +
+				"`email` text," +
+				"`dob` datetime," +
+				"`phone` text," +
+				"`street` text," +
+				"`city` text," +
+				"`state` text," +
+				"`zip` text," +
+				"`country` text," +
+				"PRIMARY KEY (`id`,`updated_at`))",
+			"CREATE INDEX IF NOT EXISTS `idx_records_deleted_at` ON `records`(`deleted_at`)",
+		},
+		Down: []string{
+			"DROP TABLE `records`",
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_webhooks",
+		Up: []string{
+			"CREATE TABLE `webhook_subscriptions` (" +
+				"`id` integer PRIMARY KEY AUTOINCREMENT," +
+				"`url` text NOT NULL," +
+				"`secret` text NOT NULL," +
+				"`fields` text NOT NULL DEFAULT ''," +
+				"`created_at` datetime NOT NULL)",
+			"CREATE TABLE `webhook_deliveries` (" +
+				"`id` integer PRIMARY KEY AUTOINCREMENT," +
+				"`subscription_id` integer NOT NULL," +
+				"`record_id` integer NOT NULL," +
+				"`event` text NOT NULL," +
+				"`version_at` datetime NOT NULL," +
+				"`previous_at` datetime," +
+				"`changed_fields` text NOT NULL DEFAULT ''," +
+				"`status` text NOT NULL," +
+				"`attempts` integer NOT NULL DEFAULT 0," +
+				"`last_error` text NOT NULL DEFAULT ''," +
+				"`next_attempt_at` datetime NOT NULL," +
+				"`created_at` datetime NOT NULL)",
+			"CREATE INDEX `idx_webhook_deliveries_status` ON `webhook_deliveries`(`status`,`next_attempt_at`)",
+		},
+		Down: []string{
+			"DROP TABLE `webhook_deliveries`",
+			"DROP TABLE `webhook_subscriptions`",
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_api_keys",
+		Up: []string{
+			"CREATE TABLE `api_keys` (" +
+				"`id` integer PRIMARY KEY AUTOINCREMENT," +
+				"`name` text NOT NULL," +
+				"`prefix` text NOT NULL," +
+				"`hash` text NOT NULL UNIQUE," +
+				"`scopes` text NOT NULL," +
+				"`created_at` datetime NOT NULL," +
+				"`revoked_at` datetime)",
+		},
+		Down: []string{
+			"DROP TABLE `api_keys`",
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_records_actor",
+		Up: []string{
+			"ALTER TABLE `records` ADD COLUMN `actor` text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE `records` DROP COLUMN `actor`",
+		},
+	},
+	{
+		// sqlite can't change a primary key, so the table is rebuilt
+		Version: 5,
+		Name:    "add_tenants",
+		Up: []string{
+			"CREATE TABLE `records_tenants` (" +
+				"`tenant` text NOT NULL DEFAULT 'default'," +
+				"`id` integer," +
+				"`created_at` datetime," +
+				"`updated_at` datetime," +
+				"`deleted_at` datetime," +
+				"`first_name` text," +
+				"`middle_name` text," +
+				"`last_name` text," +
+				"`email` text," +
+				"`dob` datetime," +
+				"`phone` text," +
+				"`street` text," +
+				"`city` text," +
+				"`state` text," +
+				"`zip` text," +
+				"`country` text," +
+				"`actor` text NOT NULL DEFAULT ''," +
+				"PRIMARY KEY (`tenant`,`id`,`updated_at`))",
+			"INSERT INTO `records_tenants` (`id`,`created_at`,`updated_at`,`deleted_at`,`first_name`,`middle_name`,`last_name`,`email`,`dob`,`phone`,`street`,`city`,`state`,`zip`,`country`,`actor`) " +
+				"SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`first_name`,`middle_name`,`last_name`,`email`,`dob`,`phone`,`street`,`city`,`state`,`zip`,`country`,`actor` FROM `records`",
+			"DROP TABLE `records`",
+			"ALTER TABLE `records_tenants` RENAME TO `records`",
+			"CREATE INDEX `idx_records_deleted_at` ON `records`(`deleted_at`)",
+			"ALTER TABLE `webhook_subscriptions` ADD COLUMN `tenant` text NOT NULL DEFAULT 'default'",
+			"ALTER TABLE `api_keys` ADD COLUMN `tenant` text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE `api_keys` DROP COLUMN `tenant`",
+			"ALTER TABLE `webhook_subscriptions` DROP COLUMN `tenant`",
+			"CREATE TABLE `records_default` (" +
+				"`id` integer," +
+				"`created_at` datetime," +
+				"`updated_at` datetime," +
+				"`deleted_at` datetime," +
+				"`first_name` text," +
+				"`middle_name` text," +
+				"`last_name` text," +
+				"`email` text," +
+				"`dob` datetime," +
+				"`phone` text," +
+				"`street` text," +
+				"`city` text," +
+				"`state` text," +
+				"`zip` text," +
+				"`country` text," +
+				"`actor` text NOT NULL DEFAULT ''," +
+				"PRIMARY KEY (`id`,`updated_at`))",
+			"INSERT INTO `records_default` (`id`,`created_at`,`updated_at`,`deleted_at`,`first_name`,`middle_name`,`last_name`,`email`,`dob`,`phone`,`street`,`city`,`state`,`zip`,`country`,`actor`) " +
+				"SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`first_name`,`middle_name`,`last_name`,`email`,`dob`,`phone`,`street`,`city`,`state`,`zip`,`country`,`actor` FROM `records` WHERE `tenant` = 'default'",
+			"DROP TABLE `records`",
+			"ALTER TABLE `records_default` RENAME TO `records`",
+			"CREATE INDEX `idx_records_deleted_at` ON `records`(`deleted_at`)",
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_record_keys",
+		Up: []string{
+			"ALTER TABLE `records` ADD COLUMN `sealed` text NOT NULL DEFAULT ''",
+			"CREATE TABLE `record_keys` (" +
+				"`tenant` text NOT NULL," +
+				"`record_id` integer NOT NULL," +
+				"`key_id` text NOT NULL," +
+				"`wrapped` blob NOT NULL," +
+				"`created_at` datetime NOT NULL," +
+				"PRIMARY KEY (`tenant`,`record_id`))",
+		},
+		Down: []string{
+			"DROP TABLE `record_keys`",
+			"ALTER TABLE `records` DROP COLUMN `sealed`",
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_erasures",
+		Up: []string{
+			"ALTER TABLE `records` ADD COLUMN `erased_at` datetime",
+			"CREATE TABLE `erasures` (" +
+				"`id` integer PRIMARY KEY AUTOINCREMENT," +
+				"`tenant` text NOT NULL," +
+				"`record_id` integer NOT NULL," +
+				"`actor` text NOT NULL," +
+				"`reason` text NOT NULL," +
+				"`versions` integer NOT NULL," +
+				"`erased_at` datetime NOT NULL)",
+			"CREATE INDEX `idx_erasures_record` ON `erasures`(`tenant`,`record_id`)",
+		},
+		Down: []string{
+			"DROP TABLE `erasures`",
+			"ALTER TABLE `records` DROP COLUMN `erased_at`",
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_version_hashes",
+		Up: []string{
+			"ALTER TABLE `records` ADD COLUMN `digest` text NOT NULL DEFAULT ''",
+			"ALTER TABLE `records` ADD COLUMN `hash` text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE `records` DROP COLUMN `hash`",
+			"ALTER TABLE `records` DROP COLUMN `digest`",
+		},
+	},
+	{
+		// timetravel_maintaining() is only true within the transactions
+		// of model.Maintain, on their connection, see model.OpenDb
+		Version: 9,
+		Name:    "append_only_records",
+		Up: []string{
+			"CREATE TABLE `maintenance` (" +
+				"`id` integer PRIMARY KEY AUTOINCREMENT," +
+				"`tenant` text NOT NULL," +
+				"`record_id` integer NOT NULL," +
+				"`actor` text NOT NULL," +
+				"`operation` text NOT NULL," +
+				"`reason` text NOT NULL," +
+				"`versions` integer NOT NULL DEFAULT 0," +
+				"`performed_at` datetime NOT NULL)",
+			"CREATE INDEX `idx_maintenance_record` ON `maintenance`(`tenant`,`record_id`)",
+			"CREATE TRIGGER `records_append_only_update` BEFORE UPDATE ON `records` " +
+				"WHEN NOT timetravel_maintaining() " +
+				"BEGIN SELECT RAISE(ABORT, 'versions of records are append-only'); END",
+			"CREATE TRIGGER `records_append_only_delete` BEFORE DELETE ON `records` " +
+				"WHEN NOT timetravel_maintaining() " +
+				"BEGIN SELECT RAISE(ABORT, 'versions of records are append-only'); END",
+			"CREATE TRIGGER `maintenance_append_only_insert` BEFORE INSERT ON `maintenance` " +
+				"WHEN NOT timetravel_maintaining() " +
+				"BEGIN SELECT RAISE(ABORT, 'the maintenance log is only written by maintenance'); END",
+			"CREATE TRIGGER `maintenance_append_only_update` BEFORE UPDATE ON `maintenance` " +
+				"BEGIN SELECT RAISE(ABORT, 'the maintenance log is append-only'); END",
+			"CREATE TRIGGER `maintenance_append_only_delete` BEFORE DELETE ON `maintenance` " +
+				"BEGIN SELECT RAISE(ABORT, 'the maintenance log is append-only'); END",
+		},
+		Down: []string{
+			"DROP TRIGGER `maintenance_append_only_delete`",
+			"DROP TRIGGER `maintenance_append_only_update`",
+			"DROP TRIGGER `maintenance_append_only_insert`",
+			"DROP TRIGGER `records_append_only_delete`",
+			"DROP TRIGGER `records_append_only_update`",
+			"DROP TABLE `maintenance`",
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add_api_key_roles",
+		Up: []string{
+			"ALTER TABLE `api_keys` ADD COLUMN `roles` text NOT NULL DEFAULT ''",
+		},
+		Down: []string{
+			"ALTER TABLE `api_keys` DROP COLUMN `roles`",
+		},
+	},
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrAppendOnly = errors.New("versions of records are append-only")
+
+// maintenanceKey marks the transactions of Maintain, see gorm.DB.Set.
+const maintenanceKey = "timetravel:maintenance"
+
+// appendOnly rejects the updates and deletes of records made through db
+// outside of Maintain, as the triggers of the database do for any
+// connection.
+func appendOnly(db *gorm.DB) error {
+	reject := func(tx *gorm.DB) {
+		if tx.Statement.Table != "records" {
+			return
+		}
+		if maintained, _ := tx.Get(maintenanceKey); maintained != true {
+			_ = tx.AddError(ErrAppendOnly)
+		}
+	}
+
+	err := db.Callback().Update().Before("gorm:update").Register("timetravel:append_only", reject)
+	if err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("timetravel:append_only", reject)
+}
+
+// Maintain runs change, which may update or delete existing versions of
+// records, in a transaction recording entry in the maintenance log.
+// change returns how many versions it changed.
+//
+// Only the transaction itself is let through the triggers of the
+// database, until it ends, see maintaining.
+func Maintain(db *gorm.DB, entry *Maintenance, change func(tx *gorm.DB) (int, error)) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := maintaining(tx)
+		if err != nil {
+			return err
+		}
+
+		// the session keeps the setting, without sharing the conditions
+		// of the statements of change
+		versions, err := change(tx.Set(maintenanceKey, true).Session(&gorm.Session{}))
+		if err != nil {
+			return err
+		}
+
+		entry.Versions = versions
+		return tx.Create(entry).Error
+	})
+}
+
+// maintaining lets the rest of the transaction tx through the triggers
+// of the database: a setting local to the transaction in postgres, or a
+// flag of the connection in sqlite, which is reset as the transaction
+// ends, see OpenDb.
+func maintaining(tx *gorm.DB) error {
+	if tx.Dialector.Name() == "postgres" {
+		return tx.Exec("SET LOCAL timetravel.maintenance = 'on'").Error
+	}
+	return tx.Exec("SELECT timetravel_maintain()").Error
+}
@@ -1,27 +1,89 @@
 package model
 
 import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-var db *gorm.DB
+// sqliteDriver is the sqlite3 driver, whose connections have the
+// functions of the append-only triggers, see maintenanceFunctions.
+const sqliteDriver = "sqlite3_timetravel"
 
-func GetDb() *gorm.DB {
-	if db != nil {
-		return db
-	}
+var errMaintenanceOutsideTransaction = errors.New("maintenance must run in a transaction")
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{ConnectHook: maintenanceFunctions})
+}
+
+// maintenanceFunctions adds timetravel_maintain() to conn, which lets
+// the rest of the transaction it's called in through the append-only
+// triggers, and timetravel_maintaining(), which they check. The flag is
+// reset as the transaction commits or rolls back, so it never outlives
+// the transaction of Maintain, and other connections never see it.
+func maintenanceFunctions(conn *sqlite3.SQLiteConn) error {
+	maintaining := false
+	conn.RegisterCommitHook(func() int {
+		maintaining = false
+		return 0
+	})
+	conn.RegisterRollbackHook(func() {
+		maintaining = false
+	})
 
-	db, err := gorm.Open(sqlite.Open("db/dev.db"), &gorm.Config{})
+	err := conn.RegisterFunc("timetravel_maintain", func() (bool, error) {
+		if conn.AutoCommit() {
+			return false, errMaintenanceOutsideTransaction
+		}
+		maintaining = true
+		return true, nil
+	}, false)
 	if err != nil {
-		panic("failed to connect database")
+		return err
 	}
+	return conn.RegisterFunc("timetravel_maintaining", func() bool {
+		return maintaining
+	}, false)
+}
 
-	return db
+// OpenDb opens a connection pool to the sqlite database at path.
+//
+// The schema is managed by the migration package.
+func OpenDb(path string) (*gorm.DB, error) {
+	// transactions take the write lock as they begin, so writers wait
+	// for each other rather than fail once they read, and new versions
+	// chain to the latest one, see chain
+	return open(sqlite.New(sqlite.Config{DriverName: sqliteDriver, DSN: path + "?_txlock=immediate"}))
 }
 
-func InitDb() {
-	db := GetDb()
+// OpenPostgresDb opens a connection pool to the postgres database at dsn.
+func OpenPostgresDb(dsn string) (*gorm.DB, error) {
+	return open(postgres.Open(dsn))
+}
+
+// open translates the constraint violations of the drivers into the
+// errors of gorm, e.g. gorm.ErrDuplicatedKey, so that they can be told
+// apart from the others on every database.
+func open(dialector gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+	return db, appendOnly(db)
+}
 
-	db.AutoMigrate(&Record{})
+// Timestamp is t as stored by db, to match the timestamps of rows:
+// formatted for sqlite, or the time itself for postgres, in UTC so that
+// sqlite, which compares them as strings, orders them as instants.
+func Timestamp(db *gorm.DB, t time.Time) interface{} {
+	t = t.UTC()
+	if db.Dialector.Name() == "postgres" {
+		return t
+	}
+	return t.Format(time.RFC3339)
 }
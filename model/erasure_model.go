@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Erasure is the audit entry of the erasure of the personal data of a
+// record, in every version it had at the time.
+type Erasure struct {
+	ID       uint   `json:"id"`
+	Tenant   string `json:"-"`
+	RecordID uint   `json:"record_id"`
+
+	// Actor is the subject of the caller who erased the record.
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+
+	// Versions is how many versions were erased.
+	Versions int       `json:"versions"`
+	ErasedAt time.Time `json:"erased_at"`
+}
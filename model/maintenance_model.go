@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// The operations changing existing versions of records.
+const (
+	MaintenanceErasure    = "erasure"
+	MaintenanceCorrection = "correction"
+	MaintenanceEncryption = "encryption"
+)
+
+// Maintenance is the audit entry of a change to existing versions of
+// records, which are otherwise append-only, see Maintain. Operations
+// spanning many records, such as encryption, have no record id.
+type Maintenance struct {
+	ID        uint   `json:"id"`
+	Tenant    string `json:"-"`
+	RecordID  uint   `json:"record_id"`
+	Actor     string `json:"actor"`
+	Operation string `json:"operation"`
+	Reason    string `json:"reason"`
+
+	// Versions is how many versions were changed.
+	Versions int `json:"versions"`
+
+	PerformedAt time.Time `json:"performed_at"`
+}
+
+func (Maintenance) TableName() string {
+	return "maintenance"
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"reflect"
 	"time"
 
@@ -10,11 +11,28 @@ import (
 )
 
 type Record struct {
+	// Tenant owns the record, whose id is only unique within the tenant.
+	Tenant    string         `gorm:"primaryKey" json:"-"`
 	ID        uint           `gorm:"primaryKey;autoIncrement:false" json:"-"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `gorm:"primaryKey" json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Actor is the subject of the caller who wrote the version.
+	Actor string `json:"actor"`
+
+	// Digest hashes the content of the version, and Hash chains it to
+	// the hash of the previous version, see chain.
+	Digest string `json:"-"`
+	Hash   string `json:"hash"`
+
+	// Sealed holds the encrypted fields of the version, which are empty
+	// in their own columns, until they are decrypted, see envelope.
+	Sealed string `json:"-"`
+
+	// ErasedAt is when the personal data of the version was erased.
+	ErasedAt *time.Time `json:"-"`
+
 	FirstName  string    `json:"first_name"`
 	MiddleName string    `json:"middle_name"`
 	LastName   string    `json:"last_name"`
@@ -28,12 +46,24 @@ type Record struct {
 	Country    string    `json:"country"`
 }
 
+// Redacted replaces the mutable fields of erased versions in their json
+// representation.
+const Redacted = "[redacted]"
+
+// IsDataField is true for the fields of Record which are part of the
+// data of its json representation.
+func IsDataField(name string) bool {
+	return name != "Tenant" && name != "ID" && name != "DeletedAt" && name != "Digest" && name != "Sealed" && name != "ErasedAt"
+}
+
 type RecordJSON struct {
 	ID   uint                   `json:"id"`
 	Data map[string]interface{} `json:"data"`
 }
 
-func (r Record) ToJSON() (RecordJSON, error) {
+// ToJSON converts the record to its json representation, leaving out
+// the omitted fields.
+func (r Record) ToJSON(omit ...string) (RecordJSON, error) {
 	v := reflect.ValueOf(r)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
@@ -42,12 +72,21 @@ func (r Record) ToJSON() (RecordJSON, error) {
 	result := make(map[string]interface{})
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Type().Field(i)
-		if field.Name == "ID" || field.Name == "DeletedAt" {
+		if !IsDataField(field.Name) {
 			continue
 		}
 		fieldKey := stringy.New(field.Name).SnakeCase().ToLower()
 		result[fieldKey] = v.Field(i).Interface()
 	}
+	if r.ErasedAt != nil {
+		for _, field := range r.MutableFields() {
+			result[field] = Redacted
+		}
+		result["erased_at"] = *r.ErasedAt
+	}
+	for _, field := range omit {
+		delete(result, field)
+	}
 
 	recordJson := RecordJSON{}
 	recordJson.ID = r.ID
@@ -117,8 +156,17 @@ func areEqual(newValue interface{}, currentValue interface{}) bool {
 		return reflect.DeepEqual(newValue, currentValue)
 	}
 
+	// a null value deletes the field, leaving it at its zero value
+	if newValue == nil {
+		return reflect.ValueOf(currentValue).IsZero()
+	}
+
 	if reflect.TypeOf(currentValue) == reflect.TypeOf(time.Time{}) {
-		parsedTime, err := time.Parse(time.RFC3339, newValue.(string))
+		newTime, ok := newValue.(string)
+		if !ok {
+			return false
+		}
+		parsedTime, err := time.Parse(time.RFC3339, newTime)
 		if err != nil {
 			log.Error().Err(err).Msg("Couldn't Convert to Time")
 			return false
@@ -154,3 +202,63 @@ func (r Record) MergeData(changedData map[string]interface{}) map[string]interfa
 
 	return mergedData
 }
+
+// Erased returns a copy of the version with its mutable fields erased
+// at the given time, which keeps its place in the chain of versions.
+func (r Record) Erased(at time.Time) Record {
+	erased := Record{
+		Tenant:    r.Tenant,
+		ID:        r.ID,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		DeletedAt: r.DeletedAt,
+		Actor:     r.Actor,
+		Digest:    r.Digest,
+		Hash:      r.Hash,
+		ErasedAt:  &at,
+	}
+	return erased
+}
+
+// WithData returns a copy of the record with its mutable fields set
+// from data. A nil value resets the field to its zero value.
+func (r Record) WithData(data map[string]interface{}) (Record, error) {
+	reflectRecord := reflect.ValueOf(&r).Elem()
+	for _, field := range r.MutableFields() {
+		value, ok := data[field]
+		if !ok {
+			continue
+		}
+
+		fieldKey := stringy.New(field).CamelCase("?", "").UcFirst()
+		rField := reflectRecord.FieldByName(fieldKey)
+		if !rField.IsValid() {
+			continue
+		}
+
+		if value == nil {
+			rField.Set(reflect.Zero(rField.Type()))
+			continue
+		}
+
+		switch rField.Interface().(type) {
+		case time.Time:
+			switch v := value.(type) {
+			case time.Time:
+				rField.Set(reflect.ValueOf(v))
+			case string:
+				parsedTime, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return Record{}, fmt.Errorf("%s: %w", field, err)
+				}
+				rField.Set(reflect.ValueOf(parsedTime))
+			default:
+				return Record{}, fmt.Errorf("%s: invalid time %v", field, value)
+			}
+		case string:
+			rField.SetString(fmt.Sprint(value))
+		}
+	}
+
+	return r, nil
+}
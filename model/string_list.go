@@ -0,0 +1,29 @@
+package model
+
+import (
+	"database/sql/driver"
+	"strings"
+)
+
+// StringList is a list of strings without commas, stored comma separated
+// in a text column.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	}
+	*l = StringList{}
+	if raw != "" {
+		*l = strings.Split(raw, ",")
+	}
+	return nil
+}
@@ -1,33 +1,187 @@
 package main
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rainbowmga/timetravel/api"
+	"github.com/rainbowmga/timetravel/api/graph"
+	"github.com/rainbowmga/timetravel/api/rpc"
+	"github.com/rainbowmga/timetravel/attest"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/config"
+	"github.com/rainbowmga/timetravel/concern/limit"
 	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/jwtauth"
 	"github.com/rainbowmga/timetravel/middleware"
-	"github.com/rainbowmga/timetravel/model"
-	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rainbowmga/timetravel/webhook"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	logging.InitLogging()
-	model.InitDb()
+
+	c, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid configuration")
+	}
+
+	// attestations are verified offline, without the database
+	if len(c.Args) > 0 && c.Args[0] == "attestation" {
+		runAttestation(c, c.Args[1:])
+		return
+	}
+
+	// the chain key must exist before the storage is opened
+	if len(c.Args) > 0 && c.Args[0] == "chain" {
+		runChain(c, c.Args[1:])
+		return
+	}
+
+	// tenants are provisioned in their own database
+	if len(c.Args) > 0 && c.Args[0] == "tenant" {
+		runTenant(c, c.Args[1:])
+		return
+	}
+
+	store, err := openStorage(c)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect database")
+	}
+
+	if store.db != nil {
+		migrator := migration.NewMigrator(store.db, store.migrations)
+
+		if len(c.Args) > 0 && c.Args[0] == "migrate" {
+			runMigrate(migrator, c.Args[1:])
+			return
+		}
+
+		pending, err := migrator.Pending()
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to check migrations")
+		}
+		if len(pending) > 0 {
+			log.Fatal().Msgf(
+				"%d pending migrations; run `timetravel migrate up` first",
+				len(pending),
+			)
+		}
+
+		if len(c.Args) > 0 && c.Args[0] == "apikey" {
+			runAPIKey(store.keys, c.Args[1:])
+			return
+		}
+
+		if len(c.Args) > 0 && c.Args[0] == "encryption" {
+			runEncryption(c, store, c.Args[1:])
+			return
+		}
+
+		if len(c.Args) > 0 && c.Args[0] == "verify" {
+			runVerify(c, store, c.Args[1:])
+			return
+		}
+
+		if store.cipher != nil {
+			err := store.cipher.Check()
+			if err != nil {
+				log.Fatal().Err(err).Msg("could not read the encryption keys; `timetravel encryption rotate` creates the key file")
+			}
+		}
+	}
+
+	if len(c.Args) > 0 {
+		log.Fatal().Msgf("unknown command %q for %s storage", c.Args[0], c.Storage)
+	}
+
+	var signer *attest.Signer
+	if c.AttestationKeyFile != "" {
+		signer, err = attest.LoadSigner(c.AttestationKeyFile, store.clock)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load the attestation key; `timetravel attestation keygen` creates it")
+		}
+	}
 
 	router := mux.NewRouter()
 
-	service := service.NewSQLiteRecordService()
-	api := api.NewAPI(&service)
+	api := api.NewAPI(store.records, store.changes, store.webhooks, store.keys, signer)
+
+	// rates are measured in real time, whatever the clock of the records
+	api.SetLimits("v1", c.V1Limits, clock.Real())
+	api.SetLimits("v2", c.V2Limits, clock.Real())
 
 	apiRoute := router.PathPrefix("/api").Subrouter()
 	api.CreateRoutes(apiRoute)
 
-	loggedRouter := middleware.AccessLogMiddleware(router)
+	graphHandler, err := graph.NewHandler(store.records)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid graphql schema")
+	}
+	graphRoute := middleware.RequireScope(auth.ScopeRead, auth.ScopeHistory)(graphHandler)
+	graphRoute = middleware.LimitMiddleware("graphql", c.GraphQLLimits, clock.Real())(graphRoute)
+	router.Path("/graphql").Handler(graphRoute).Methods("GET", "POST")
+
+	metricsRoute := middleware.RequireScope(auth.ScopeAdmin)(http.HandlerFunc(middleware.MetricsHandler))
+	router.Path("/metrics").Handler(metricsRoute).Methods("GET")
 
-	address := "127.0.0.1:8000"
+	if store.webhooks != nil {
+		go webhook.NewDispatcher(store.webhooks, clock.Real()).Run(context.Background())
+	}
+
+	// a nil authenticator lets every caller in
+	var authenticator auth.Authenticator
+	switch c.Auth {
+	case config.AuthAPIKey:
+		authenticator = store.keys
+	case config.AuthJWT:
+		// tokens expire in real time, whatever the clock of the records
+		verifier, err := jwtauth.NewVerifier(c.JWKS, clock.Real())
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load the jwks")
+		}
+		verifier.Issuer = c.JWTIssuer
+		verifier.Audience = c.JWTAudience
+		authenticator = verifier
+	default:
+		log.Warn().Msg("authentication is disabled")
+	}
+
+	if authenticator != nil {
+		fieldRoles := auth.DefaultFieldRoles
+		if c.FieldRoles != "" {
+			fieldRoles, err = auth.LoadFieldRoles(c.FieldRoles)
+			if err != nil {
+				log.Fatal().Err(err).Msg("could not load the field roles")
+			}
+		}
+		authenticator = fieldRoles.Restrict(authenticator)
+	}
+
+	// the failures to authenticate of every ip, over http and grpc
+	failures := limit.NewLimiter(c.AuthFailureLimits, clock.Real())
+
+	if c.GRPCAddress != "" {
+		go serveGRPC(c, rpc.NewServer(store.records, store.changes), authenticator, failures)
+	}
+
+	authRouter := middleware.AuthMiddleware(authenticator)(middleware.TenantMiddleware(router))
+	if authenticator != nil {
+		authRouter = middleware.AuthFailureLimitMiddleware(failures)(authRouter)
+	}
+	loggedRouter := middleware.AccessLogMiddleware(authRouter)
+	if header := clockHeader(c); header != "" {
+		loggedRouter = middleware.ClockMiddleware(header)(loggedRouter)
+	}
+
+	address := c.Address
 	srv := &http.Server{
 		Handler:      loggedRouter,
 		Addr:         address,
@@ -36,6 +190,25 @@ func main() {
 	}
 
 	log.Info().Msgf("listening on http://%s", address)
-	err := srv.ListenAndServe()
+	err = srv.ListenAndServe()
+	log.Fatal().Err(err).Msg("")
+}
+
+// serveGRPC serves the records over gRPC next to the http server.
+func serveGRPC(c config.Config, records *rpc.Server, authenticator auth.Authenticator, failures *limit.Limiter) {
+	address := c.GRPCAddress
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to listen for grpc")
+	}
+
+	// the limits follow the authentication, which tells callers apart
+	options := rpc.AuthInterceptors(authenticator, failures)
+	options = append(options, rpc.LimitInterceptors(c.GRPCLimits, clock.Real())...)
+	srv := grpc.NewServer(options...)
+	records.Register(srv)
+
+	log.Info().Msgf("listening on grpc://%s", address)
+	err = srv.Serve(lis)
 	log.Fatal().Err(err).Msg("")
 }
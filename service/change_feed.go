@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/model"
+)
+
+const (
+	// subscriberBuffer is how many changes a subscriber can fall behind
+	// before it is dropped.
+	subscriberBuffer = 64
+
+	// historySize is how many of the latest changes are kept to be
+	// replayed to subscribers resuming after a sequence number.
+	historySize = 1024
+)
+
+// Change is a new version of a record, numbered by a sequence shared by
+// every record.
+type Change struct {
+	Sequence uint64
+	Record   model.Record
+}
+
+// ChangeFeed fans out every new version of a record to its subscribers.
+//
+// Sequence numbers start from 1 in every process, and only the latest
+// changes are kept for replay.
+type ChangeFeed struct {
+	mu          sync.Mutex
+	sequence    uint64
+	history     []Change
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	tenant  string
+	id      uint
+	changes chan Change
+}
+
+func (s *subscriber) watches(record model.Record) bool {
+	return record.Tenant == s.tenant && (s.id == 0 || s.id == record.ID)
+}
+
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Subscribe returns the changes of the record with that id, or of every
+// record when id is 0, of the tenant of ctx, published after the given sequence number or from
+// now on when after is 0.
+//
+// A sequence number above the latest one was handed out by an earlier
+// process, in which case every change still kept is replayed.
+//
+// The channel is closed once cancel is called, or when the subscriber
+// falls too far behind.
+func (f *ChangeFeed) Subscribe(ctx context.Context, id uint, after uint64) (changes <-chan Change, cancel func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sub := &subscriber{tenant.FromContext(ctx), id, nil}
+	var replay []Change
+	if after > 0 {
+		if after > f.sequence {
+			after = 0
+		}
+		for _, change := range f.history {
+			if change.Sequence > after && sub.watches(change.Record) {
+				replay = append(replay, change)
+			}
+		}
+	}
+
+	sub.changes = make(chan Change, len(replay)+subscriberBuffer)
+	for _, change := range replay {
+		sub.changes <- change
+	}
+	f.subscribers[sub] = struct{}{}
+
+	return sub.changes, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.remove(sub)
+	}
+}
+
+// Publish numbers a new version and sends it to the subscribers
+// watching it.
+func (f *ChangeFeed) Publish(record model.Record) Change {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sequence++
+	change := Change{f.sequence, record}
+
+	f.history = append(f.history, change)
+	if len(f.history) > historySize {
+		f.history = f.history[len(f.history)-historySize:]
+	}
+
+	for sub := range f.subscribers {
+		if !sub.watches(record) {
+			continue
+		}
+		select {
+		case sub.changes <- change:
+		default:
+			f.remove(sub)
+		}
+	}
+
+	return change
+}
+
+// Erase erases the changes of a record kept for replay.
+func (f *ChangeFeed) Erase(erasure model.Erasure) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, change := range f.history {
+		record := change.Record
+		if record.Tenant == erasure.Tenant && record.ID == erasure.RecordID && record.ErasedAt == nil {
+			f.history[i].Record = record.Erased(erasure.ErasedAt)
+		}
+	}
+}
+
+func (f *ChangeFeed) remove(sub *subscriber) {
+	if _, ok := f.subscribers[sub]; ok {
+		delete(f.subscribers, sub)
+		close(sub.changes)
+	}
+}
+
+// PublishingRecordService publishes every version written through the
+// wrapped RecordService to a ChangeFeed.
+type PublishingRecordService struct {
+	RecordService
+	feed *ChangeFeed
+}
+
+func NewPublishingRecordService(records RecordService, feed *ChangeFeed) PublishingRecordService {
+	return PublishingRecordService{records, feed}
+}
+
+func (s *PublishingRecordService) CreateRecord(ctx context.Context, id uint, unsafeData map[string]interface{}) (model.Record, error) {
+	record, err := s.RecordService.CreateRecord(ctx, id, unsafeData)
+	if err == nil {
+		s.feed.Publish(record)
+	}
+	return record, err
+}
+
+func (s *PublishingRecordService) UpdateRecord(ctx context.Context, prevRecord model.Record, unsafeData map[string]interface{}) (model.Record, error) {
+	record, err := s.RecordService.UpdateRecord(ctx, prevRecord, unsafeData)
+	// a no-op update returns the previous version as is
+	if err == nil && !record.UpdatedAt.Equal(prevRecord.UpdatedAt) {
+		s.feed.Publish(record)
+	}
+	return record, err
+}
+
+func (s *PublishingRecordService) EraseRecord(ctx context.Context, id uint, reason string) (model.Erasure, error) {
+	erasure, err := s.RecordService.EraseRecord(ctx, id, reason)
+	if err == nil {
+		s.feed.Erase(erasure)
+	}
+	return erasure, err
+}
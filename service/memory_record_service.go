@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rs/zerolog/log"
+)
+
+// MemoryRecordService is an in-memory implementation of RecordService.
+//
+// It is safe for concurrent use, and versions records the same way as
+// SQLiteRecordService, but everything is lost once the process exits.
+type MemoryRecordService struct {
+	mu       *sync.RWMutex
+	clock    clock.Clock
+	chainKey chain.Key
+
+	// versions holds every version of a record, oldest first.
+	versions map[recordKey][]model.Record
+
+	// erasures holds the erasures of a record, oldest first.
+	erasures      map[recordKey][]model.Erasure
+	lastErasureID uint
+
+	// maintenance holds the changes to the versions of a record, oldest
+	// first.
+	maintenance       map[recordKey][]model.Maintenance
+	lastMaintenanceID uint
+}
+
+// recordKey identifies a record among those of every tenant.
+type recordKey struct {
+	tenant string
+	id     uint
+}
+
+func keyOf(ctx context.Context, id uint) recordKey {
+	return recordKey{tenant.FromContext(ctx), id}
+}
+
+func NewMemoryRecordService(clock clock.Clock) MemoryRecordService {
+	return MemoryRecordService{
+		mu:          &sync.RWMutex{},
+		clock:       clock,
+		versions:    make(map[recordKey][]model.Record),
+		erasures:    make(map[recordKey][]model.Erasure),
+		maintenance: make(map[recordKey][]model.Maintenance),
+	}
+}
+
+// SetChainKey keys the digests and links of the chain of the versions
+// with key.
+func (s *MemoryRecordService) SetChainKey(key chain.Key) {
+	s.chainKey = key
+}
+
+func (s *MemoryRecordService) GetRecord(ctx context.Context, id uint) (model.Record, error) {
+	return s.GetRecordAt(ctx, id, s.clock.Now())
+}
+
+func (s *MemoryRecordService) GetRecordAt(ctx context.Context, id uint, at time.Time) (model.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.versions[keyOf(ctx, id)]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].UpdatedAt.After(at) {
+			return versions[i], nil
+		}
+	}
+
+	return model.Record{}, ErrRecordDoesNotExist
+}
+
+func (s *MemoryRecordService) GetVersions(ctx context.Context, id uint) ([]model.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.versions[keyOf(ctx, id)]
+	if len(versions) == 0 {
+		return []model.Record{}, ErrRecordDoesNotExist
+	}
+
+	records := make([]model.Record, len(versions))
+	for i, version := range versions {
+		records[len(versions)-1-i] = version
+	}
+
+	return records, nil
+}
+
+func (s *MemoryRecordService) CreateRecord(ctx context.Context, id uint, unsafeData map[string]interface{}) (model.Record, error) {
+	log.Debug().Msg("CreateRecord")
+
+	safeData := model.Record{}.SanitizePayload(unsafeData, false)
+	if len(safeData) == 0 {
+		log.Debug().Msg("Skipped Create, Nothing to Create!")
+		return model.Record{}, ErrRecordEmpty
+	}
+
+	now := s.clock.Now().Truncate(time.Second)
+	record, err := model.Record{
+		Tenant:    tenant.FromContext(ctx),
+		ID:        id,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Actor:     actorOf(ctx),
+	}.WithData(safeData)
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := keyOf(ctx, id)
+	if len(s.versions[key]) > 0 {
+		return model.Record{}, ErrRecordAlreadyExists
+	}
+	record.Digest = chain.Digest(s.chainKey, record)
+	record.Hash = chain.Link(s.chainKey, "", record.Digest)
+	s.versions[key] = []model.Record{record}
+
+	log.Debug().Msg("Record Created")
+	return record, nil
+}
+
+func (s *MemoryRecordService) UpdateRecord(ctx context.Context, prevRecord model.Record, unsafeData map[string]interface{}) (model.Record, error) {
+	log.Debug().Msg("UpdateRecord")
+
+	if isOtherTenant(ctx, prevRecord) {
+		return model.Record{}, ErrRecordDoesNotExist
+	}
+
+	safeData := model.Record{}.SanitizePayload(unsafeData, true)
+	changedData := prevRecord.ExtractChangedData(safeData)
+	if len(changedData) == 0 {
+		log.Debug().Msg("Skipped Update, Nothing to Update!")
+		return prevRecord, nil
+	}
+
+	record, err := model.Record{
+		Tenant:    prevRecord.Tenant,
+		ID:        prevRecord.ID,
+		CreatedAt: prevRecord.CreatedAt,
+		UpdatedAt: s.clock.Now().Truncate(time.Second),
+		Actor:     actorOf(ctx),
+	}.WithData(prevRecord.MergeData(changedData))
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := keyOf(ctx, prevRecord.ID)
+	versions := s.versions[key]
+	if len(versions) == 0 {
+		return model.Record{}, ErrRecordDoesNotExist
+	}
+	if !record.UpdatedAt.After(versions[len(versions)-1].UpdatedAt) {
+		return model.Record{}, ErrVersionExists
+	}
+	record.Digest = chain.Digest(s.chainKey, record)
+	record.Hash = chain.Link(s.chainKey, versions[len(versions)-1].Hash, record.Digest)
+	s.versions[key] = append(versions, record)
+
+	log.Debug().Msg("Record Updated")
+	return record, nil
+}
+
+func (s *MemoryRecordService) EraseRecord(ctx context.Context, id uint, reason string) (model.Erasure, error) {
+	log.Debug().Msg("EraseRecord")
+
+	if s.chainKey == nil {
+		return model.Erasure{}, ErrErasureWithoutChainKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := keyOf(ctx, id)
+	versions := s.versions[key]
+	if len(versions) == 0 {
+		return model.Erasure{}, ErrRecordDoesNotExist
+	}
+
+	now := s.clock.Now().Truncate(time.Second)
+	erased := 0
+	for i, version := range versions {
+		if version.ErasedAt == nil {
+			versions[i] = version.Erased(now)
+			erased++
+		}
+	}
+
+	s.lastErasureID++
+	erasure := model.Erasure{
+		ID:       s.lastErasureID,
+		Tenant:   key.tenant,
+		RecordID: id,
+		Actor:    actorOf(ctx),
+		Reason:   reason,
+		Versions: erased,
+		ErasedAt: now,
+	}
+	s.erasures[key] = append(s.erasures[key], erasure)
+	s.maintain(key, model.Maintenance{
+		Actor:       erasure.Actor,
+		Operation:   model.MaintenanceErasure,
+		Reason:      reason,
+		Versions:    erased,
+		PerformedAt: now,
+	})
+
+	log.Debug().Msgf("Erased %d Versions", erased)
+	return erasure, nil
+}
+
+func (s *MemoryRecordService) GetErasures(ctx context.Context, id uint) ([]model.Erasure, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]model.Erasure{}, s.erasures[keyOf(ctx, id)]...), nil
+}
+
+func (s *MemoryRecordService) CorrectVersion(ctx context.Context, id uint, at time.Time, unsafeData map[string]interface{}, reason string) (model.Maintenance, error) {
+	log.Debug().Msg("CorrectVersion")
+
+	safeData := model.Record{}.SanitizePayload(unsafeData, true)
+	if len(safeData) == 0 {
+		return model.Maintenance{}, ErrCorrectionEmpty
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := keyOf(ctx, id)
+	versions := s.versions[key]
+	if len(versions) == 0 {
+		return model.Maintenance{}, ErrRecordDoesNotExist
+	}
+	corrected := -1
+	for i, version := range versions {
+		if version.UpdatedAt.Equal(at) {
+			corrected = i
+		}
+	}
+	if corrected < 0 {
+		return model.Maintenance{}, ErrVersionDoesNotExist
+	}
+	if versions[corrected].ErasedAt != nil {
+		return model.Maintenance{}, ErrVersionErased
+	}
+
+	version, err := versions[corrected].WithData(safeData)
+	if err != nil {
+		return model.Maintenance{}, err
+	}
+	version.Digest = chain.Digest(s.chainKey, version)
+	versions[corrected] = version
+
+	// the later versions chain to the corrected one again
+	prev := ""
+	if corrected > 0 {
+		prev = versions[corrected-1].Hash
+	}
+	for i := corrected; i < len(versions); i++ {
+		versions[i].Hash = chain.Link(s.chainKey, prev, versions[i].Digest)
+		prev = versions[i].Hash
+	}
+
+	entry := s.maintain(key, model.Maintenance{
+		Actor:       actorOf(ctx),
+		Operation:   model.MaintenanceCorrection,
+		Reason:      reason,
+		Versions:    len(versions) - corrected,
+		PerformedAt: s.clock.Now().Truncate(time.Second),
+	})
+
+	log.Debug().Msgf("Corrected %d Versions", entry.Versions)
+	return entry, nil
+}
+
+func (s *MemoryRecordService) GetMaintenance(ctx context.Context, id uint) ([]model.Maintenance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]model.Maintenance{}, s.maintenance[keyOf(ctx, id)]...), nil
+}
+
+func (s *MemoryRecordService) VerifyRecord(ctx context.Context, id uint) (chain.Report, error) {
+	return verifyRecord(ctx, s, s.chainKey, id)
+}
+
+// maintain records entry in the maintenance of the record of key, which
+// must be locked for writing.
+func (s *MemoryRecordService) maintain(key recordKey, entry model.Maintenance) model.Maintenance {
+	s.lastMaintenanceID++
+	entry.ID = s.lastMaintenanceID
+	entry.Tenant = key.tenant
+	entry.RecordID = key.id
+	s.maintenance[key] = append(s.maintenance[key], entry)
+	return entry
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"gorm.io/gorm"
+)
+
+// PostgresRecordService is a Postgres implementation of RecordService.
+//
+// Timestamps are truncated to the second to match the precision of
+// SQLiteRecordService.
+type PostgresRecordService struct {
+	sqlRecordService
+}
+
+func NewPostgresRecordService(db *gorm.DB, clock clock.Clock) PostgresRecordService {
+	return PostgresRecordService{sqlRecordService{db: db, clock: clock}}
+}
@@ -3,21 +3,40 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
 	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rainbowmga/timetravel/concern/tenant"
 	"github.com/rainbowmga/timetravel/model"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 var ErrRecordDoesNotExist = errors.New("record with that id does not exist")
 var ErrRecordIDInvalid = errors.New("record id must >= 0")
 var ErrRecordAlreadyExists = errors.New("record already exists")
+var ErrRecordEmpty = errors.New("record has no fields to create")
+var ErrVersionExists = errors.New("record version already exists at that time")
+var ErrVersionDoesNotExist = errors.New("record has no version at that time")
+var ErrVersionErased = errors.New("record version was erased")
+var ErrCorrectionEmpty = errors.New("correction has no fields to change")
+var ErrErasureWithoutChainKey = errors.New("records can't be erased without a chain key")
+var ErrTenantDoesNotExist = errors.New("tenant does not exist; it must be provisioned first")
+
+// Records belong to the tenant of the context of the calls, see
+// tenant.FromContext, and are invisible to other tenants.
 
 // Implements method to get, create, and update record data.
 type RecordService interface {
 
 	// GetRecord will retrieve an record.
+	//
+	// All methods retrieving records return ErrRecordDoesNotExist
+	// if there is no record with that id.
 	GetRecord(ctx context.Context, id uint) (model.Record, error)
 
 	// GetRecordAt will retrieve a record's version at a given timestamp
@@ -36,35 +55,480 @@ type RecordService interface {
 	//
 	// UpdateRecord will error if id <= 0 or the record does not exist with that id.
 	UpdateRecord(ctx context.Context, prevRecord model.Record, unsafeData map[string]interface{}) (model.Record, error)
+
+	// EraseRecord will erase the mutable fields of every version of a
+	// record, which keep their timestamps and are marked as erased, and
+	// return the audit entry of the erasure.
+	//
+	// Versions written afterwards are not erased.
+	//
+	// EraseRecord will error without a chain key, since the digests erased
+	// versions keep would then confirm guesses of their content.
+	EraseRecord(ctx context.Context, id uint, reason string) (model.Erasure, error)
+
+	// GetErasures will retrieve the erasures of a record, oldest first.
+	GetErasures(ctx context.Context, id uint) ([]model.Erasure, error)
+
+	// CorrectVersion will replace fields of the version of a record
+	// updated at the given timestamp, as an exception to versions being
+	// append-only, chain the later versions to it again, and return the
+	// audit entry of the correction.
+	//
+	// It returns ErrVersionDoesNotExist if no version was updated at
+	// exactly that time, and ErrVersionErased if it was erased.
+	CorrectVersion(ctx context.Context, id uint, at time.Time, unsafeData map[string]interface{}, reason string) (model.Maintenance, error)
+
+	// GetMaintenance will retrieve the audit entries of the changes to
+	// the existing versions of a record, oldest first.
+	GetMaintenance(ctx context.Context, id uint) ([]model.Maintenance, error)
+
+	// VerifyRecord will verify the chain of the versions of a record
+	// with the key of the service, see chain.Verify.
+	VerifyRecord(ctx context.Context, id uint) (chain.Report, error)
+}
+
+// verifyRecord verifies the chain of the versions of a record of the
+// tenant of ctx with key.
+func verifyRecord(ctx context.Context, s RecordService, key chain.Key, id uint) (chain.Report, error) {
+	versions, err := s.GetVersions(ctx, id)
+	if err != nil {
+		return chain.Report{}, err
+	}
+	erasures, err := s.GetErasures(ctx, id)
+	if err != nil {
+		return chain.Report{}, err
+	}
+	return chain.Verify(key, versions, erasures), nil
+}
+
+// VersionHook is called with every new version written by the sql
+// implementations of RecordService, inside the transaction inserting it,
+// so the version is rolled back if the hook fails. prev is nil when the
+// record was just created.
+type VersionHook func(tx *gorm.DB, prev *model.Record, record model.Record) error
+
+// ErasureHook is called with every erasure by the sql implementations of
+// RecordService, inside its transaction, to erase the copies of the
+// personal data of the record kept elsewhere.
+type ErasureHook func(tx *gorm.DB, erasure model.Erasure) error
+
+// Sealer encrypts fields of the versions written by the sql
+// implementations of RecordService, and decrypts them when they are
+// read, see envelope.Cipher. The services only ever see plaintext.
+type Sealer interface {
+	// Seal replaces the fields of data it encrypts, inside the
+	// transaction writing the version.
+	Seal(tx *gorm.DB, tenant string, id uint, data map[string]interface{}) error
+
+	// Open decrypts the fields of records in place.
+	Open(db *gorm.DB, records ...*model.Record) error
+
+	// Shred deletes the key of the record, so that its encrypted fields
+	// can never be decrypted again.
+	Shred(tx *gorm.DB, tenant string, id uint) error
+}
+
+// open decrypts records with sealer, if any.
+func open(db *gorm.DB, sealer Sealer, records ...*model.Record) error {
+	if sealer == nil {
+		return nil
+	}
+	return sealer.Open(db, records...)
+}
+
+// actorOf is the subject of the principal of a request, to which the
+// versions it writes are attributed. It is empty outside of requests.
+func actorOf(ctx context.Context) string {
+	principal, _ := auth.FromContext(ctx)
+	return principal.Subject
+}
+
+// scoped restricts the queries of db to the records of the tenant of ctx.
+func scoped(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.WithContext(ctx).Where("tenant = ?", tenant.FromContext(ctx))
+}
+
+// isOtherTenant is true for records which don't belong to the tenant of
+// ctx, which must not be updated from it.
+func isOtherTenant(ctx context.Context, record model.Record) bool {
+	return record.Tenant != tenant.FromContext(ctx)
+}
+
+// writeVersion inserts a version of a record, sealed by sealer if any,
+// and runs the hooks in one transaction, returning the version as read
+// back from the database.
+//
+// It returns ErrRecordAlreadyExists if prev is nil and the record has
+// versions, and ErrVersionExists if the record has a version updated at
+// the same time or later, as the other implementations of RecordService.
+func writeVersion(db *gorm.DB, hooks []VersionHook, sealer Sealer, key chain.Key, prev *model.Record, data map[string]interface{}) (model.Record, error) {
+	var record model.Record
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := link(tx, key, data, prev == nil)
+		if err != nil {
+			return err
+		}
+
+		if sealer != nil {
+			err := sealer.Seal(tx, data["tenant"].(string), data["id"].(uint), data)
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&model.Record{}).Create(data)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			if prev == nil {
+				return ErrRecordAlreadyExists
+			}
+			return ErrVersionExists
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Where("tenant = ?", data["tenant"]).
+			Order("updated_at desc").
+			First(&record, data["id"])
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := open(tx, sealer, &record); err != nil {
+			return err
+		}
+
+		for _, hook := range hooks {
+			if err := hook(tx, prev, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return record, err
+}
+
+// link sets the digest of the version of data, and its hash chained to
+// the latest version of the record with key, see chain. It must run before the
+// fields are sealed.
+//
+// create is true for the first version of a record, which must not
+// have any.
+func link(tx *gorm.DB, key chain.Key, data map[string]interface{}, create bool) error {
+	version, err := versionOf(data)
+	if err != nil {
+		return err
+	}
+
+	err = lock(tx, version.Tenant, version.ID)
+	if err != nil {
+		return err
+	}
+
+	var latest []model.Record
+	err = tx.Unscoped().
+		Select("hash", "updated_at").
+		Where("tenant = ? AND id = ?", version.Tenant, version.ID).
+		Order("updated_at desc").
+		Limit(1).
+		Find(&latest).Error
+	if err != nil {
+		return err
+	}
+	prev := ""
+	switch {
+	case create && len(latest) > 0:
+		return ErrRecordAlreadyExists
+	case !create && len(latest) == 0:
+		return ErrRecordDoesNotExist
+	case !create && !version.UpdatedAt.After(latest[0].UpdatedAt):
+		return ErrVersionExists
+	case !create:
+		prev = latest[0].Hash
+	}
+
+	data["digest"] = chain.Digest(key, version)
+	data["hash"] = chain.Link(key, prev, data["digest"].(string))
+	return nil
+}
+
+// lock makes the postgres writers of a record wait for each other until
+// the end of the transaction tx, so that they chain to the latest
+// version, as sqlite writers already do, see model.OpenDb.
+func lock(tx *gorm.DB, tenant string, id uint) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("%s/%d", tenant, id)).Error
+}
+
+// versionOf is the version that data is written as, whose timestamps
+// are formatted for sqlite, or times for postgres.
+func versionOf(data map[string]interface{}) (model.Record, error) {
+	version, err := model.Record{
+		Tenant: data["tenant"].(string),
+		ID:     data["id"].(uint),
+		Actor:  data["actor"].(string),
+	}.WithData(data)
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	for field, at := range map[string]*time.Time{
+		"created_at": &version.CreatedAt,
+		"updated_at": &version.UpdatedAt,
+	} {
+		switch value := data[field].(type) {
+		case time.Time:
+			*at = value
+		case string:
+			*at, err = time.Parse(time.RFC3339, value)
+			if err != nil {
+				return model.Record{}, fmt.Errorf("%s: %w", field, err)
+			}
+		}
+	}
+	return version, nil
+}
+
+// eraseVersions erases the versions of the record of erasure at
+// erasedAt, shreds its key and runs the hooks in one maintenance
+// transaction, see model.Maintain, returning the erasure as recorded.
+func eraseVersions(db *gorm.DB, hooks []ErasureHook, sealer Sealer, erasure model.Erasure, erasedAt interface{}) (model.Erasure, error) {
+	entry := model.Maintenance{
+		Tenant:      erasure.Tenant,
+		RecordID:    erasure.RecordID,
+		Actor:       erasure.Actor,
+		Operation:   model.MaintenanceErasure,
+		Reason:      erasure.Reason,
+		PerformedAt: erasure.ErasedAt,
+	}
+	err := model.Maintain(db, &entry, func(tx *gorm.DB) (int, error) {
+		// UpdateColumns leaves updated_at, which dates the version, as is
+		redacted := map[string]interface{}{"sealed": "", "erased_at": erasedAt}
+		for _, field := range (model.Record{}).MutableFields() {
+			redacted[field] = nil
+		}
+		result := tx.Model(&model.Record{}).
+			Where("tenant = ? AND id = ? AND erased_at IS NULL", erasure.Tenant, erasure.RecordID).
+			UpdateColumns(redacted)
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		erasure.Versions = int(result.RowsAffected)
+
+		if erasure.Versions == 0 {
+			var count int64
+			err := tx.Model(&model.Record{}).
+				Where("tenant = ? AND id = ?", erasure.Tenant, erasure.RecordID).
+				Count(&count).Error
+			if err != nil {
+				return 0, err
+			}
+			if count == 0 {
+				return 0, ErrRecordDoesNotExist
+			}
+		}
+
+		if sealer != nil {
+			err := sealer.Shred(tx, erasure.Tenant, erasure.RecordID)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		err := tx.Create(&erasure).Error
+		if err != nil {
+			return 0, err
+		}
+		for _, hook := range hooks {
+			if err := hook(tx, erasure); err != nil {
+				return 0, err
+			}
+		}
+		return erasure.Versions, nil
+	})
+	return erasure, err
+}
+
+// correctVersion replaces the fields of the version of the record of
+// entry updated at at by those of safeData, sealed by sealer if any, and
+// chains the later versions to it again with key in one maintenance transaction,
+// see model.Maintain.
+func correctVersion(db *gorm.DB, sealer Sealer, key chain.Key, entry *model.Maintenance, at time.Time, safeData map[string]interface{}) error {
+	return model.Maintain(db, entry, func(tx *gorm.DB) (int, error) {
+		err := lock(tx, entry.Tenant, entry.RecordID)
+		if err != nil {
+			return 0, err
+		}
+
+		var versions []model.Record
+		err = tx.Unscoped().
+			Where("tenant = ? AND id = ?", entry.Tenant, entry.RecordID).
+			Order("updated_at").
+			Find(&versions).Error
+		if err != nil {
+			return 0, err
+		}
+		if len(versions) == 0 {
+			return 0, ErrRecordDoesNotExist
+		}
+
+		corrected := -1
+		opened := make([]*model.Record, len(versions))
+		for i := range versions {
+			opened[i] = &versions[i]
+			if versions[i].UpdatedAt.Equal(at) {
+				corrected = i
+			}
+		}
+		if corrected < 0 {
+			return 0, ErrVersionDoesNotExist
+		}
+		if versions[corrected].ErasedAt != nil {
+			return 0, ErrVersionErased
+		}
+		if err := open(tx, sealer, opened...); err != nil {
+			return 0, err
+		}
+
+		version, err := versions[corrected].WithData(safeData)
+		if err != nil {
+			return 0, err
+		}
+		versions[corrected] = version
+
+		prev := ""
+		if corrected > 0 {
+			prev = versions[corrected-1].Hash
+		}
+		for i := corrected; i < len(versions); i++ {
+			version := versions[i]
+			columns := map[string]interface{}{}
+			if i == corrected {
+				columns = version.GetData()
+				if sealer != nil {
+					err := sealer.Seal(tx, version.Tenant, version.ID, columns)
+					if err != nil {
+						return 0, err
+					}
+				}
+			}
+			switch {
+			case i == corrected:
+				version.Digest = chain.Digest(key, version)
+			case version.Digest == "" && version.ErasedAt == nil:
+				// written before versions were chained
+				version.Digest = chain.Digest(key, version)
+			}
+			columns["digest"] = version.Digest
+			columns["hash"] = chain.Link(key, prev, version.Digest)
+			prev = columns["hash"].(string)
+
+			// UpdateColumns leaves updated_at, which dates the version, as is
+			err := tx.Model(&model.Record{}).
+				Where("tenant = ? AND id = ? AND updated_at = ?", version.Tenant, version.ID, model.Timestamp(tx, version.UpdatedAt)).
+				UpdateColumns(columns).Error
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(versions) - corrected, nil
+	})
+}
+
+// getErasures lists the erasures of a record of the tenant of ctx.
+func getErasures(ctx context.Context, db *gorm.DB, id uint) ([]model.Erasure, error) {
+	erasures := []model.Erasure{}
+	err := db.WithContext(ctx).
+		Where("tenant = ? AND record_id = ?", tenant.FromContext(ctx), id).
+		Order("id").
+		Find(&erasures).Error
+	return erasures, err
+}
+
+// getMaintenance lists the maintenance of a record of the tenant of ctx.
+func getMaintenance(ctx context.Context, db *gorm.DB, id uint) ([]model.Maintenance, error) {
+	entries := []model.Maintenance{}
+	err := db.WithContext(ctx).
+		Where("tenant = ? AND record_id = ?", tenant.FromContext(ctx), id).
+		Order("id").
+		Find(&entries).Error
+	return entries, err
 }
 
 // SQLiteRecordService is a SQLite implementation of RecordService.
-type SQLiteRecordService struct{}
+type SQLiteRecordService struct {
+	sqlRecordService
+}
 
-func NewSQLiteRecordService() SQLiteRecordService {
-	return SQLiteRecordService{}
+func NewSQLiteRecordService(db *gorm.DB, clock clock.Clock) SQLiteRecordService {
+	return SQLiteRecordService{sqlRecordService{db: db, clock: clock}}
 }
 
-func (s *SQLiteRecordService) GetRecord(ctx context.Context, id uint) (model.Record, error) {
-	return s.GetRecordAt(ctx, id, time.Now())
+// sqlRecordService implements RecordService for every sql database,
+// which only differ in how they store timestamps, see timestamp.
+type sqlRecordService struct {
+	db           *gorm.DB
+	clock        clock.Clock
+	hooks        []VersionHook
+	erasureHooks []ErasureHook
+	sealer       Sealer
+	key          chain.Key
 }
 
-func (s *SQLiteRecordService) GetRecordAt(ctx context.Context, id uint, at time.Time) (model.Record, error) {
-	db := model.GetDb()
+// timestamp is t as the database of the service stores it, truncated to
+// the second, see model.Timestamp.
+func (s *sqlRecordService) timestamp(t time.Time) interface{} {
+	return model.Timestamp(s.db, t.Truncate(time.Second))
+}
+
+// AddVersionHook runs hook in the transaction of every new version.
+func (s *sqlRecordService) AddVersionHook(hook VersionHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// AddErasureHook runs hook in the transaction of every erasure.
+func (s *sqlRecordService) AddErasureHook(hook ErasureHook) {
+	s.erasureHooks = append(s.erasureHooks, hook)
+}
+
+// SetSealer encrypts fields of the versions with sealer.
+func (s *sqlRecordService) SetSealer(sealer Sealer) {
+	s.sealer = sealer
+}
+
+// SetChainKey keys the digests and links of the chain of the versions
+// with key.
+func (s *sqlRecordService) SetChainKey(key chain.Key) {
+	s.key = key
+}
+
+func (s *sqlRecordService) GetRecord(ctx context.Context, id uint) (model.Record, error) {
+	return s.GetRecordAt(ctx, id, s.clock.Now())
+}
+
+func (s *sqlRecordService) GetRecordAt(ctx context.Context, id uint, at time.Time) (model.Record, error) {
+	db := scoped(ctx, s.db)
 
 	var record model.Record
 	result := db.Order("updated_at desc").
-		Where("updated_at <= ?", at.Format(time.RFC3339)).
+		Where("updated_at <= ?", s.timestamp(at)).
 		First(&record, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return model.Record{}, ErrRecordDoesNotExist
+	}
 	if result.Error != nil {
 		return model.Record{}, result.Error
 	}
+	if err := open(s.db.WithContext(ctx), s.sealer, &record); err != nil {
+		return model.Record{}, err
+	}
 
 	return record, nil
 }
 
-func (s *SQLiteRecordService) GetVersions(ctx context.Context, id uint) ([]model.Record, error) {
-	db := model.GetDb()
+func (s *sqlRecordService) GetVersions(ctx context.Context, id uint) ([]model.Record, error) {
+	db := scoped(ctx, s.db)
 
 	var records []model.Record
 	result := db.Order("updated_at desc").Find(&records, id)
@@ -73,42 +537,57 @@ func (s *SQLiteRecordService) GetVersions(ctx context.Context, id uint) ([]model
 	}
 
 	if len(records) == 0 {
-		return []model.Record{}, errors.New("Record doesn't exist!")
+		return []model.Record{}, ErrRecordDoesNotExist
+	}
+
+	versions := make([]*model.Record, len(records))
+	for i := range records {
+		versions[i] = &records[i]
+	}
+	if err := open(s.db.WithContext(ctx), s.sealer, versions...); err != nil {
+		return []model.Record{}, err
 	}
 
 	return records, nil
 }
 
-func (s *SQLiteRecordService) CreateRecord(ctx context.Context, id uint, unsafeData map[string]interface{}) (model.Record, error) {
+func (s *sqlRecordService) CreateRecord(ctx context.Context, id uint, unsafeData map[string]interface{}) (model.Record, error) {
 	log.Debug().Msg("CreateRecord")
 
 	safeData := model.Record{}.SanitizePayload(unsafeData, false)
 
 	numSafeFields := len(safeData)
 
-	db := model.GetDb()
+	db := s.db.WithContext(ctx)
 	if numSafeFields > 0 {
 		log.Debug().Msg("Running Create")
+		now := s.timestamp(s.clock.Now())
+		safeData["tenant"] = tenant.FromContext(ctx)
 		safeData["id"] = id
-		safeData["created_at"] = time.Now().Format(time.RFC3339)
-		safeData["updated_at"] = time.Now().Format(time.RFC3339)
-		result := db.Model(&model.Record{}).Create(safeData)
-		if result.Error != nil {
-			logging.LogError(result.Error)
-			return model.Record{}, result.Error
+		safeData["created_at"] = now
+		safeData["updated_at"] = now
+		safeData["actor"] = actorOf(ctx)
+		record, err := writeVersion(db, s.hooks, s.sealer, s.key, nil, safeData)
+		if err != nil {
+			logging.LogError(err)
+			return model.Record{}, err
 		} else {
 			log.Debug().Msg("Record Created")
-			return s.GetRecord(ctx, id)
+			return record, nil
 		}
 	} else {
 		log.Debug().Msg("Skipped Create, Nothing to Create!")
-		return model.Record{}, errors.New("No Fields to Create Record!")
+		return model.Record{}, ErrRecordEmpty
 	}
 }
 
-func (s *SQLiteRecordService) UpdateRecord(ctx context.Context, prevRecord model.Record, unsafeData map[string]interface{}) (model.Record, error) {
+func (s *sqlRecordService) UpdateRecord(ctx context.Context, prevRecord model.Record, unsafeData map[string]interface{}) (model.Record, error) {
 	log.Debug().Msg("UpdateRecord")
 
+	if isOtherTenant(ctx, prevRecord) {
+		return model.Record{}, ErrRecordDoesNotExist
+	}
+
 	safeData := model.Record{}.SanitizePayload(unsafeData, true)
 	log.Debug().Msgf("Num Safe Fields: %d", len(safeData))
 	changedData := prevRecord.ExtractChangedData(safeData)
@@ -116,23 +595,86 @@ func (s *SQLiteRecordService) UpdateRecord(ctx context.Context, prevRecord model
 	numChangedFields := len(changedData)
 	log.Debug().Msgf("Num Changed Fields: %d", numChangedFields)
 
-	db := model.GetDb()
+	db := s.db.WithContext(ctx)
 	if numChangedFields > 0 {
 		log.Debug().Msg("Running Updated")
 		newRecordData := prevRecord.MergeData(changedData)
+		newRecordData["tenant"] = prevRecord.Tenant
 		newRecordData["id"] = prevRecord.ID
-		newRecordData["created_at"] = prevRecord.CreatedAt.Format(time.RFC3339)
-		newRecordData["updated_at"] = time.Now().Format(time.RFC3339)
-		result := db.Model(&model.Record{}).Create(newRecordData)
-		if result.Error != nil {
-			logging.LogError(result.Error)
-			return model.Record{}, result.Error
+		newRecordData["created_at"] = s.timestamp(prevRecord.CreatedAt)
+		newRecordData["updated_at"] = s.timestamp(s.clock.Now())
+		newRecordData["actor"] = actorOf(ctx)
+		record, err := writeVersion(db, s.hooks, s.sealer, s.key, &prevRecord, newRecordData)
+		if err != nil {
+			logging.LogError(err)
+			return model.Record{}, err
 		} else {
 			log.Debug().Msg("Record Updated")
-			return s.GetRecord(ctx, prevRecord.ID)
+			return record, nil
 		}
 	} else {
 		log.Debug().Msg("Skipped Update, Nothing to Update!")
 		return prevRecord, nil
 	}
 }
+
+func (s *sqlRecordService) EraseRecord(ctx context.Context, id uint, reason string) (model.Erasure, error) {
+	log.Debug().Msg("EraseRecord")
+
+	if s.key == nil {
+		return model.Erasure{}, ErrErasureWithoutChainKey
+	}
+
+	now := s.clock.Now().Truncate(time.Second)
+	erasure := model.Erasure{
+		Tenant:   tenant.FromContext(ctx),
+		RecordID: id,
+		Actor:    actorOf(ctx),
+		Reason:   reason,
+		ErasedAt: now,
+	}
+	erasure, err := eraseVersions(s.db.WithContext(ctx), s.erasureHooks, s.sealer, erasure, s.timestamp(now))
+	if err != nil {
+		return model.Erasure{}, err
+	}
+
+	log.Debug().Msgf("Erased %d Versions", erasure.Versions)
+	return erasure, nil
+}
+
+func (s *sqlRecordService) GetErasures(ctx context.Context, id uint) ([]model.Erasure, error) {
+	return getErasures(ctx, s.db, id)
+}
+
+func (s *sqlRecordService) CorrectVersion(ctx context.Context, id uint, at time.Time, unsafeData map[string]interface{}, reason string) (model.Maintenance, error) {
+	log.Debug().Msg("CorrectVersion")
+
+	safeData := model.Record{}.SanitizePayload(unsafeData, true)
+	if len(safeData) == 0 {
+		return model.Maintenance{}, ErrCorrectionEmpty
+	}
+
+	entry := model.Maintenance{
+		Tenant:      tenant.FromContext(ctx),
+		RecordID:    id,
+		Actor:       actorOf(ctx),
+		Operation:   model.MaintenanceCorrection,
+		Reason:      reason,
+		PerformedAt: s.clock.Now().Truncate(time.Second),
+	}
+	err := correctVersion(s.db.WithContext(ctx), s.sealer, s.key, &entry, at, safeData)
+	if err != nil {
+		return model.Maintenance{}, err
+	}
+
+	log.Debug().Msgf("Corrected %d Versions", entry.Versions)
+	return entry, nil
+}
+
+func (s *sqlRecordService) GetMaintenance(ctx context.Context, id uint) ([]model.Maintenance, error) {
+	return getMaintenance(ctx, s.db, id)
+}
+
+func (s *sqlRecordService) VerifyRecord(ctx context.Context, id uint) (chain.Report, error) {
+	return verifyRecord(ctx, s, s.key, id)
+}
@@ -0,0 +1,273 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/envelope"
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/service/servicetest"
+	"gorm.io/gorm"
+)
+
+func TestMemoryRecordService(t *testing.T) {
+	servicetest.Run(t, func(t *testing.T, clock clock.Clock) service.RecordService {
+		s := service.NewMemoryRecordService(clock)
+		s.SetChainKey(servicetest.ChainKey)
+		return &s
+	})
+}
+
+func TestSQLiteRecordService(t *testing.T) {
+	servicetest.Run(t, func(t *testing.T, clock clock.Clock) service.RecordService {
+		db, err := model.OpenDb(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrate(t, db, migration.SQLite)
+
+		s := service.NewSQLiteRecordService(db, clock)
+		s.SetChainKey(servicetest.ChainKey)
+		return &s
+	})
+}
+
+// TestEncryptedSQLiteRecordService encrypts every field of the records.
+func TestEncryptedSQLiteRecordService(t *testing.T) {
+	servicetest.Run(t, func(t *testing.T, clock clock.Clock) service.RecordService {
+		dir := t.TempDir()
+		db, err := model.OpenDb(filepath.Join(dir, "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrate(t, db, migration.SQLite)
+
+		keyFile := filepath.Join(dir, "keys.json")
+		if _, err := envelope.AddKey(keyFile); err != nil {
+			t.Fatal(err)
+		}
+
+		s := service.NewSQLiteRecordService(db, clock)
+		s.SetChainKey(servicetest.ChainKey)
+		s.SetSealer(envelope.NewCipher(envelope.NewKeyring(keyFile), model.Record{}.MutableFields()))
+		return &s
+	})
+}
+
+// TestTenantRecordService keeps every tenant in a sqlite file of its own.
+func TestTenantRecordService(t *testing.T) {
+	servicetest.Run(t, func(t *testing.T, clock clock.Clock) service.RecordService {
+		dir := t.TempDir()
+		return service.NewTenantRecordService(func(tenant string) (service.RecordService, error) {
+			db, err := model.OpenDb(filepath.Join(dir, tenant+".db"))
+			if err != nil {
+				return nil, err
+			}
+			migrate(t, db, migration.SQLite)
+
+			s := service.NewSQLiteRecordService(db, clock)
+			s.SetChainKey(servicetest.ChainKey)
+			return &s, nil
+		})
+	})
+}
+
+// TestTenantRecordServiceUnknownTenant opens a tenant which wasn't
+// provisioned.
+func TestTenantRecordServiceUnknownTenant(t *testing.T) {
+	opened := 0
+	s := service.NewTenantRecordService(func(name string) (service.RecordService, error) {
+		opened++
+		return nil, service.ErrTenantDoesNotExist
+	})
+	ctx := tenant.WithTenant(context.Background(), "ghost")
+
+	_, err := s.GetRecord(ctx, 1)
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetRecord err = %v, want ErrRecordDoesNotExist", err)
+	}
+	_, err = s.CreateRecord(ctx, 1, map[string]interface{}{"first_name": "Steve"})
+	if !errors.Is(err, service.ErrTenantDoesNotExist) {
+		t.Errorf("CreateRecord err = %v, want ErrTenantDoesNotExist", err)
+	}
+	if opened != 2 {
+		t.Errorf("opened the tenant %d times, want every time until it is provisioned", opened)
+	}
+}
+
+// TestEraseRecordWithoutChainKey erases with services whose digests
+// anyone can recompute.
+func TestEraseRecordWithoutChainKey(t *testing.T) {
+	db, err := model.OpenDb(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrate(t, db, migration.SQLite)
+
+	fake := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC))
+	memory := service.NewMemoryRecordService(fake)
+	sqlite := service.NewSQLiteRecordService(db, fake)
+
+	ctx := context.Background()
+	for name, s := range map[string]service.RecordService{"Memory": &memory, "SQLite": &sqlite} {
+		if _, err := s.CreateRecord(ctx, 1, map[string]interface{}{"first_name": "Steve"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.EraseRecord(ctx, 1, "gdpr request")
+		if !errors.Is(err, service.ErrErasureWithoutChainKey) {
+			t.Errorf("%s: EraseRecord err = %v, want ErrErasureWithoutChainKey", name, err)
+		}
+		record, err := s.GetRecord(ctx, 1)
+		if err != nil || record.FirstName != "Steve" {
+			t.Errorf("%s: got %+v, %v, want the record left as is", name, record, err)
+		}
+	}
+}
+
+// TestPostgresRecordService runs against the database at
+// TIMETRAVEL_TEST_POSTGRES_DSN, e.g. started with `make test-postgres`.
+// Each test runs in its own schema, which is dropped afterwards.
+func TestPostgresRecordService(t *testing.T) {
+	dsn := os.Getenv("TIMETRAVEL_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TIMETRAVEL_TEST_POSTGRES_DSN is not set")
+	}
+
+	servicetest.Run(t, func(t *testing.T, clock clock.Clock) service.RecordService {
+		schema := fmt.Sprintf("test_%d", rand.Int63())
+
+		admin, err := model.OpenPostgresDb(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = admin.Exec("CREATE SCHEMA " + schema).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		})
+
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		db, err := model.OpenPostgresDb(dsn + separator + "search_path=" + schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrate(t, db, migration.Postgres)
+
+		s := service.NewPostgresRecordService(db, clock)
+		s.SetChainKey(servicetest.ChainKey)
+		return &s
+	})
+}
+
+// TestAppendOnly changes versions of records outside of the
+// maintenance path, through GORM and in raw sql.
+func TestAppendOnly(t *testing.T) {
+	db, err := model.OpenDb(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrate(t, db, migration.SQLite)
+
+	clock := clock.NewFake(servicetest.Start)
+	s := service.NewSQLiteRecordService(db, clock)
+	_, err = s.CreateRecord(context.Background(), 1, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	_, err = s.CorrectVersion(context.Background(), 1, servicetest.Start, map[string]interface{}{"city": "Cupertino"}, "typo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Model(&model.Record{}).Where("id = ?", 1).UpdateColumn("first_name", "Jane").Error
+	if !errors.Is(err, model.ErrAppendOnly) {
+		t.Errorf("UpdateColumn err = %v, want ErrAppendOnly", err)
+	}
+	err = db.Where("id = ?", 1).Delete(&model.Record{}).Error
+	if !errors.Is(err, model.ErrAppendOnly) {
+		t.Errorf("Delete err = %v, want ErrAppendOnly", err)
+	}
+
+	for _, sql := range []string{
+		"UPDATE records SET first_name = 'Jane'",
+		"DELETE FROM records",
+		"UPDATE maintenance SET reason = ''",
+		"DELETE FROM maintenance",
+	} {
+		err := db.Exec(sql).Error
+		if err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("%s err = %v, want append-only", sql, err)
+		}
+	}
+
+	// a maintenance entry written outside of model.Maintain doesn't let
+	// the changes that follow through
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("INSERT INTO maintenance (tenant, record_id, actor, operation, reason, performed_at) " +
+			"VALUES ('default', 1, 'mallory', 'correction', '', '2024-08-25T16:13:02Z')").Error
+		if err == nil || !strings.Contains(err.Error(), "only written by maintenance") {
+			t.Errorf("INSERT INTO maintenance err = %v, want only written by maintenance", err)
+		}
+		return tx.Exec("UPDATE records SET first_name = 'Jane'").Error
+	})
+	if err == nil || !strings.Contains(err.Error(), "append-only") {
+		t.Errorf("UPDATE after INSERT INTO maintenance err = %v, want append-only", err)
+	}
+	err = db.Exec("SELECT timetravel_maintain()").Error
+	if err == nil {
+		t.Errorf("timetravel_maintain() outside of a transaction err = nil, want an error")
+	}
+
+	// nor does a maintenance transaction once it rolled back, on the
+	// only connection
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	rollback := errors.New("rollback")
+	err = model.Maintain(db, &model.Maintenance{Operation: "test"}, func(tx *gorm.DB) (int, error) {
+		return 0, rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Maintain err = %v, want rollback", err)
+	}
+	err = db.Exec("UPDATE records SET first_name = 'Jane'").Error
+	if err == nil || !strings.Contains(err.Error(), "append-only") {
+		t.Errorf("UPDATE after a rolled back maintenance err = %v, want append-only", err)
+	}
+
+	record, err := s.GetRecord(context.Background(), 1)
+	if err != nil || record.FirstName != "Steve" || record.City != "Cupertino" {
+		t.Errorf("GetRecord = %+v, %v, want the corrected version", record, err)
+	}
+	maintenance, err := s.GetMaintenance(context.Background(), 1)
+	if err != nil || len(maintenance) != 1 || maintenance[0].Reason != "typo" {
+		t.Errorf("GetMaintenance = %+v, %v, want the correction", maintenance, err)
+	}
+}
+
+func migrate(t *testing.T, db *gorm.DB, migrations []migration.Migration) {
+	t.Helper()
+	_, err := migration.NewMigrator(db, migrations).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,733 @@
+// Package servicetest is a conformance test suite for implementations
+// of service.RecordService.
+//
+// Every implementation should pass it:
+//
+//	func TestMyRecordService(t *testing.T) {
+//		servicetest.Run(t, func(t *testing.T, clock clock.Clock) service.RecordService {
+//			s := NewMyRecordService(clock)
+//			return &s
+//		})
+//	}
+package servicetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/auth"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+)
+
+// Factory returns a new, empty RecordService for a single test, which
+// must take the current time from clock, and key its chain with
+// ChainKey.
+type Factory func(t *testing.T, clock clock.Clock) service.RecordService
+
+// ChainKey is the key of the chain of the services under test.
+var ChainKey = chain.Key("servicetest chain key of 32 bytes")
+
+// Start is the time of the fake clock at the beginning of every test.
+var Start = time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC)
+
+// Run runs the conformance suite against the services returned by
+// newService.
+//
+// Versions are timestamped with a precision of one second, so the
+// suite advances the clock by a second between writes that must
+// produce distinct versions.
+func Run(t *testing.T, newService Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s service.RecordService, clock *clock.Fake)
+	}{
+		{"Create", testCreate},
+		{"CreateWithoutFields", testCreateWithoutFields},
+		{"CreateIgnoresImmutableFields", testCreateIgnoresImmutableFields},
+		{"DoesNotExist", testDoesNotExist},
+		{"AlreadyExists", testAlreadyExists},
+		{"Update", testUpdate},
+		{"NoOpUpdate", testNoOpUpdate},
+		{"NullDeletesField", testNullDeletesField},
+		{"TimeTravelBoundaries", testTimeTravelBoundaries},
+		{"TimeTravelAcrossOffsets", testTimeTravelAcrossOffsets},
+		{"VersionOrdering", testVersionOrdering},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"Attribution", testAttribution},
+		{"Tenants", testTenants},
+		{"Erasure", testErasure},
+		{"Chain", testChain},
+		{"Correction", testCorrection},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewFake(Start)
+			tt.test(t, newService(t, clock), clock)
+		})
+	}
+}
+
+func testCreate(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	created := mustCreate(t, s, 1, map[string]interface{}{
+		"first_name": "Steve",
+		"last_name":  "Jobs",
+		"dob":        "1955-02-24T00:00:00-07:00",
+	})
+	if created.ID != 1 {
+		t.Errorf("created.ID = %d, want 1", created.ID)
+	}
+	assertData(t, created, map[string]interface{}{
+		"first_name":  "Steve",
+		"last_name":   "Jobs",
+		"middle_name": "",
+	})
+	if !created.Dob.Equal(mustParse(t, "1955-02-24T00:00:00-07:00")) {
+		t.Errorf("created.Dob = %v", created.Dob)
+	}
+	if !created.CreatedAt.Equal(created.UpdatedAt) {
+		t.Errorf("created_at %v != updated_at %v", created.CreatedAt, created.UpdatedAt)
+	}
+
+	got, err := s.GetRecord(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	assertSameVersion(t, got, created)
+}
+
+func testCreateWithoutFields(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	_, err := s.CreateRecord(ctx, 1, map[string]interface{}{"unknown": "x"})
+	if err == nil {
+		t.Fatal("CreateRecord without mutable fields succeeded")
+	}
+
+	_, err = s.GetRecord(ctx, 1)
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetRecord error = %v, want ErrRecordDoesNotExist", err)
+	}
+}
+
+func testCreateIgnoresImmutableFields(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	created := mustCreate(t, s, 7, map[string]interface{}{
+		"id":         99,
+		"created_at": "2000-01-01T00:00:00Z",
+		"first_name": "Steve",
+	})
+
+	if created.ID != 7 {
+		t.Errorf("created.ID = %d, want 7", created.ID)
+	}
+	if created.CreatedAt.Year() == 2000 {
+		t.Errorf("created_at was taken from the payload")
+	}
+}
+
+func testDoesNotExist(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	_, err := s.GetRecord(ctx, 32)
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetRecord error = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	_, err = s.GetRecordAt(ctx, 32, clock.Now())
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetRecordAt error = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	_, err = s.GetVersions(ctx, 32)
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetVersions error = %v, want ErrRecordDoesNotExist", err)
+	}
+}
+
+func testAlreadyExists(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	created := mustCreate(t, s, 1, map[string]interface{}{"first_name": "Steve"})
+	clock.Advance(time.Second)
+
+	_, err := s.CreateRecord(ctx, 1, map[string]interface{}{"first_name": "Steven"})
+	if !errors.Is(err, service.ErrRecordAlreadyExists) {
+		t.Errorf("CreateRecord of an existing record error = %v, want ErrRecordAlreadyExists", err)
+	}
+
+	updated := mustUpdate(t, s, created, map[string]interface{}{"first_name": "Steven"})
+
+	// a version at the same time as the latest one
+	_, err = s.UpdateRecord(ctx, updated, map[string]interface{}{"first_name": "Steven Paul"})
+	if !errors.Is(err, service.ErrVersionExists) {
+		t.Errorf("UpdateRecord at the time of the latest version error = %v, want ErrVersionExists", err)
+	}
+
+	versions, err := s.GetVersions(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("len(versions) = %d, want 2", len(versions))
+	}
+}
+
+func testUpdate(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	created := mustCreate(t, s, 1, map[string]interface{}{
+		"first_name": "Steve",
+		"last_name":  "Jobs",
+	})
+	clock.Advance(time.Second)
+
+	updated := mustUpdate(t, s, created, map[string]interface{}{
+		"first_name": "Steven",
+		"city":       "Cupertino",
+	})
+	assertData(t, updated, map[string]interface{}{
+		"first_name": "Steven",
+		"last_name":  "Jobs",
+		"city":       "Cupertino",
+	})
+	if !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("created_at changed from %v to %v", created.CreatedAt, updated.CreatedAt)
+	}
+	if !updated.UpdatedAt.After(created.UpdatedAt) {
+		t.Errorf("updated_at %v is not after %v", updated.UpdatedAt, created.UpdatedAt)
+	}
+
+	got, err := s.GetRecord(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	assertSameVersion(t, got, updated)
+
+	versions, err := s.GetVersions(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("len(versions) = %d, want 2", len(versions))
+	}
+}
+
+func testNoOpUpdate(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	created := mustCreate(t, s, 1, map[string]interface{}{
+		"first_name": "Steve",
+		"dob":        "1955-02-24T00:00:00-07:00",
+	})
+	clock.Advance(time.Second)
+
+	updated := mustUpdate(t, s, created, map[string]interface{}{
+		"first_name": "Steve",
+		"dob":        "1955-02-24T00:00:00-07:00",
+		"unknown":    "ignored",
+	})
+	assertSameVersion(t, updated, created)
+
+	versions, err := s.GetVersions(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != 1 {
+		t.Errorf("len(versions) = %d, want 1", len(versions))
+	}
+}
+
+func testNullDeletesField(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	created := mustCreate(t, s, 1, map[string]interface{}{
+		"first_name":  "Steven",
+		"middle_name": "Paul",
+		"dob":         "1955-02-24T00:00:00-07:00",
+	})
+	clock.Advance(time.Second)
+
+	updated := mustUpdate(t, s, created, map[string]interface{}{
+		"middle_name": nil,
+		"dob":         nil,
+	})
+	assertData(t, updated, map[string]interface{}{
+		"first_name":  "Steven",
+		"middle_name": "",
+	})
+	if !updated.Dob.IsZero() {
+		t.Errorf("dob = %v, want zero", updated.Dob)
+	}
+}
+
+func testTimeTravelBoundaries(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	v1 := mustCreate(t, s, 1, map[string]interface{}{"first_name": "Steve"})
+	clock.Advance(time.Second)
+	v2 := mustUpdate(t, s, v1, map[string]interface{}{"first_name": "Steven"})
+
+	_, err := s.GetRecordAt(ctx, 1, v1.UpdatedAt.Add(-time.Second))
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetRecordAt before creation error = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	cases := []struct {
+		name string
+		at   time.Time
+		want model.Record
+	}{
+		{"AtCreation", v1.UpdatedAt, v1},
+		{"JustBeforeUpdate", v2.UpdatedAt.Add(-time.Millisecond), v1},
+		{"AtUpdate", v2.UpdatedAt, v2},
+		{"AfterUpdate", v2.UpdatedAt.Add(time.Hour), v2},
+	}
+	for _, c := range cases {
+		got, err := s.GetRecordAt(ctx, 1, c.at)
+		if err != nil {
+			t.Errorf("%s: GetRecordAt: %v", c.name, err)
+			continue
+		}
+		if got.FirstName != c.want.FirstName {
+			t.Errorf("%s: first_name = %q, want %q", c.name, got.FirstName, c.want.FirstName)
+		}
+	}
+}
+
+// testTimeTravelAcrossOffsets writes and reads versions with clocks at
+// different offsets from UTC, which must compare as instants.
+func testTimeTravelAcrossOffsets(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+	west := time.FixedZone("UTC-7", -7*60*60)
+	east := time.FixedZone("UTC+9", 9*60*60)
+
+	v1 := mustCreate(t, s, 1, map[string]interface{}{"first_name": "Steve"})
+	clock.Set(Start.Add(time.Second).In(west))
+	v2 := mustUpdate(t, s, v1, map[string]interface{}{"first_name": "Steven"})
+
+	_, err := s.GetRecordAt(ctx, 1, Start.Add(-time.Second).In(east))
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetRecordAt before creation error = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	cases := []struct {
+		name string
+		at   time.Time
+		want model.Record
+	}{
+		{"AtCreation", Start.In(west), v1},
+		{"JustBeforeUpdate", v2.UpdatedAt.Add(-time.Millisecond).In(east), v1},
+		{"AtUpdate", v2.UpdatedAt.UTC(), v2},
+		{"AfterUpdate", v2.UpdatedAt.Add(time.Hour).In(east), v2},
+	}
+	for _, c := range cases {
+		got, err := s.GetRecordAt(ctx, 1, c.at)
+		if err != nil {
+			t.Errorf("%s: GetRecordAt: %v", c.name, err)
+			continue
+		}
+		if got.FirstName != c.want.FirstName {
+			t.Errorf("%s: first_name = %q, want %q", c.name, got.FirstName, c.want.FirstName)
+		}
+	}
+
+	got, err := s.GetRecord(ctx, 1)
+	if err != nil || got.FirstName != "Steven" {
+		t.Errorf("GetRecord = %q, %v, want the update", got.FirstName, err)
+	}
+}
+
+func testVersionOrdering(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+
+	record := mustCreate(t, s, 30, map[string]interface{}{"first_name": "Steve"})
+	names := []string{"Steve", "Steven", "Steven P.", "Steven Paul"}
+	for _, name := range names[1:] {
+		clock.Advance(time.Second)
+		record = mustUpdate(t, s, record, map[string]interface{}{"first_name": name})
+	}
+
+	versions, err := s.GetVersions(ctx, 30)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != len(names) {
+		t.Fatalf("len(versions) = %d, want %d", len(versions), len(names))
+	}
+
+	for i, version := range versions {
+		want := names[len(names)-1-i]
+		if version.FirstName != want {
+			t.Errorf("versions[%d].first_name = %q, want %q", i, version.FirstName, want)
+		}
+		if version.ID != 30 {
+			t.Errorf("versions[%d].id = %d, want 30", i, version.ID)
+		}
+		if i > 0 && !version.UpdatedAt.Before(versions[i-1].UpdatedAt) {
+			t.Errorf("versions[%d] is not older than versions[%d]", i, i-1)
+		}
+	}
+}
+
+func testConcurrentWriters(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+	const writers = 16
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func(id uint) {
+			defer wg.Done()
+			name := fmt.Sprintf("writer %d", id)
+			_, err := s.CreateRecord(ctx, id, map[string]interface{}{"first_name": name})
+			if err != nil {
+				errs <- fmt.Errorf("CreateRecord(%d): %w", id, err)
+				return
+			}
+			record, err := s.GetRecord(ctx, id)
+			if err != nil {
+				errs <- fmt.Errorf("GetRecord(%d): %w", id, err)
+				return
+			}
+			if record.FirstName != name {
+				errs <- fmt.Errorf("record %d first_name = %q, want %q", id, record.FirstName, name)
+			}
+		}(uint(i))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// testConcurrentUpdates updates the same record from many writers,
+// which retry when another one wrote a version at the same time.
+func testConcurrentUpdates(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+	const writers = 16
+
+	mustCreate(t, s, 1, map[string]interface{}{"first_name": "Steve"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			name := fmt.Sprintf("writer %d", writer)
+			for attempt := 0; ; attempt++ {
+				prev, err := s.GetRecord(ctx, 1)
+				if err != nil {
+					errs <- fmt.Errorf("%s: GetRecord(1): %w", name, err)
+					return
+				}
+				clock.Advance(time.Second)
+				_, err = s.UpdateRecord(ctx, prev, map[string]interface{}{"first_name": name})
+				if errors.Is(err, service.ErrVersionExists) && attempt < 100 {
+					continue
+				}
+				if err != nil {
+					errs <- fmt.Errorf("%s: UpdateRecord(1): %w", name, err)
+				}
+				return
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	versions, err := s.GetVersions(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != writers+1 {
+		t.Errorf("len(versions) = %d, want %d", len(versions), writers+1)
+	}
+
+	report, err := s.VerifyRecord(ctx, 1)
+	if err != nil || !report.Valid || report.Versions != writers+1 {
+		t.Errorf("VerifyRecord = %+v, %v, want %d valid versions", report, err, writers+1)
+	}
+}
+
+func testAttribution(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	steve := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "steve"})
+	jane := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "jane"})
+
+	created, err := s.CreateRecord(steve, 1, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	clock.Advance(time.Second)
+	_, err = s.UpdateRecord(jane, created, map[string]interface{}{"first_name": "Steven"})
+	if err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+
+	versions, err := s.GetVersions(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Actor != "jane" || versions[1].Actor != "steve" {
+		t.Errorf("versions = %+v, want written by jane then steve", versions)
+	}
+}
+
+func testTenants(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	steve, err := s.CreateRecord(acme, 1, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Fatalf("CreateRecord(acme): %v", err)
+	}
+	_, err = s.CreateRecord(globex, 1, map[string]interface{}{"first_name": "Jane"})
+	if err != nil {
+		t.Fatalf("CreateRecord(globex): %v", err)
+	}
+	_, err = s.GetRecord(context.Background(), 1)
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("GetRecord(default) err = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	clock.Advance(time.Second)
+	_, err = s.UpdateRecord(globex, steve, map[string]interface{}{"first_name": "Mallory"})
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("UpdateRecord(globex, acme's record) err = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	record, err := s.GetRecordAt(acme, 1, clock.Now())
+	if err != nil {
+		t.Fatalf("GetRecordAt(acme): %v", err)
+	}
+	assertData(t, record, map[string]interface{}{"first_name": "Steve"})
+
+	versions, err := s.GetVersions(globex, 1)
+	if err != nil {
+		t.Fatalf("GetVersions(globex): %v", err)
+	}
+	if len(versions) != 1 || versions[0].GetData()["first_name"] != "Jane" {
+		t.Errorf("GetVersions(globex) = %+v, want only Jane", versions)
+	}
+}
+
+func testErasure(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	created := mustCreate(t, s, 1, map[string]interface{}{"first_name": "Steve", "email": "steve@apple.com"})
+	clock.Advance(time.Second)
+	updated := mustUpdate(t, s, created, map[string]interface{}{"city": "Cupertino"})
+	mustCreate(t, s, 2, map[string]interface{}{"first_name": "Jane"})
+
+	clock.Advance(time.Second)
+	erasure, err := s.EraseRecord(context.Background(), 1, "gdpr request")
+	if err != nil {
+		t.Fatalf("EraseRecord: %v", err)
+	}
+	if erasure.RecordID != 1 || erasure.Versions != 2 || erasure.Reason != "gdpr request" ||
+		!erasure.ErasedAt.Equal(clock.Now()) {
+		t.Errorf("EraseRecord = %+v, want 2 versions erased now", erasure)
+	}
+
+	versions, err := s.GetVersions(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("GetVersions = %+v, want 2 versions", versions)
+	}
+	for i, want := range []model.Record{updated, created} {
+		version := versions[i]
+		if version.ErasedAt == nil || !version.UpdatedAt.Equal(want.UpdatedAt) ||
+			!version.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("version %d = %+v, want erased at %v", i, version, want.UpdatedAt)
+		}
+		assertData(t, version, map[string]interface{}{"first_name": "", "email": "", "city": ""})
+	}
+
+	// the other records are left as is
+	jane, err := s.GetRecord(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("GetRecord(2): %v", err)
+	}
+	assertData(t, jane, map[string]interface{}{"first_name": "Jane"})
+
+	// versions written afterwards are only erased by another erasure
+	clock.Advance(time.Second)
+	mustUpdate(t, s, versions[0], map[string]interface{}{"city": "Palo Alto"})
+	again, err := s.EraseRecord(context.Background(), 1, "gdpr request")
+	if err != nil || again.Versions != 1 {
+		t.Errorf("EraseRecord again = %+v, %v, want 1 version erased", again, err)
+	}
+
+	erasures, err := s.GetErasures(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetErasures: %v", err)
+	}
+	if len(erasures) != 2 || erasures[0].ID != erasure.ID || erasures[1].ID != again.ID {
+		t.Errorf("GetErasures = %+v, want both erasures", erasures)
+	}
+
+	_, err = s.EraseRecord(context.Background(), 3, "gdpr request")
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("EraseRecord(3) err = %v, want ErrRecordDoesNotExist", err)
+	}
+}
+
+func testChain(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	created := mustCreate(t, s, 1, map[string]interface{}{"first_name": "Steve"})
+	clock.Advance(time.Second)
+	updated := mustUpdate(t, s, created, map[string]interface{}{"dob": "1955-02-24T00:00:00-07:00"})
+	if created.Hash == "" || updated.Hash != chain.Link(ChainKey, created.Hash, updated.Digest) {
+		t.Errorf("hashes %q then %q, want the update chained to the creation", created.Hash, updated.Hash)
+	}
+
+	report, err := s.VerifyRecord(context.Background(), 1)
+	if err != nil || !report.Valid || report.Versions != 2 {
+		t.Errorf("VerifyRecord = %+v, %v, want 2 valid versions", report, err)
+	}
+
+	// erasing the versions keeps their chain
+	clock.Advance(time.Second)
+	_, err = s.EraseRecord(context.Background(), 1, "gdpr request")
+	if err != nil {
+		t.Fatalf("EraseRecord: %v", err)
+	}
+	report, err = s.VerifyRecord(context.Background(), 1)
+	if err != nil || !report.Valid {
+		t.Errorf("VerifyRecord after erasure = %+v, %v, want valid", report, err)
+	}
+
+	_, err = s.VerifyRecord(context.Background(), 2)
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("VerifyRecord(2) err = %v, want ErrRecordDoesNotExist", err)
+	}
+}
+
+func testCorrection(t *testing.T, s service.RecordService, clock *clock.Fake) {
+	ctx := context.Background()
+	created := mustCreate(t, s, 1, map[string]interface{}{"first_name": "Steev", "email": "steve@apple.com"})
+	clock.Advance(time.Second)
+	updated := mustUpdate(t, s, created, map[string]interface{}{"city": "Cupertino"})
+
+	clock.Advance(time.Second)
+	entry, err := s.CorrectVersion(ctx, 1, created.UpdatedAt, map[string]interface{}{"first_name": "Steve", "id": 2}, "typo")
+	if err != nil {
+		t.Fatalf("CorrectVersion: %v", err)
+	}
+	if entry.RecordID != 1 || entry.Operation != model.MaintenanceCorrection || entry.Reason != "typo" ||
+		entry.Versions != 2 || !entry.PerformedAt.Equal(clock.Now()) {
+		t.Errorf("CorrectVersion = %+v, want the 2 versions from the corrected one", entry)
+	}
+
+	// the version keeps its place, and the later versions are left as is
+	versions, err := s.GetVersions(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != 2 || !versions[1].UpdatedAt.Equal(created.UpdatedAt) {
+		t.Fatalf("GetVersions = %+v, want 2 versions", versions)
+	}
+	assertData(t, versions[1], map[string]interface{}{"first_name": "Steve", "email": "steve@apple.com"})
+	assertData(t, versions[0], updated.GetData())
+
+	report, err := s.VerifyRecord(ctx, 1)
+	if err != nil || !report.Valid || report.Versions != 2 {
+		t.Errorf("VerifyRecord after correction = %+v, %v, want 2 valid versions", report, err)
+	}
+
+	maintenance, err := s.GetMaintenance(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetMaintenance: %v", err)
+	}
+	if len(maintenance) != 1 || maintenance[0].ID != entry.ID || maintenance[0].Actor != entry.Actor {
+		t.Errorf("GetMaintenance = %+v, want the correction", maintenance)
+	}
+
+	_, err = s.CorrectVersion(ctx, 1, created.UpdatedAt.Add(time.Millisecond), map[string]interface{}{"city": "x"}, "typo")
+	if !errors.Is(err, service.ErrVersionDoesNotExist) {
+		t.Errorf("CorrectVersion between versions err = %v, want ErrVersionDoesNotExist", err)
+	}
+	_, err = s.CorrectVersion(ctx, 1, created.UpdatedAt, map[string]interface{}{"id": 2}, "typo")
+	if !errors.Is(err, service.ErrCorrectionEmpty) {
+		t.Errorf("CorrectVersion without fields err = %v, want ErrCorrectionEmpty", err)
+	}
+	_, err = s.CorrectVersion(ctx, 2, created.UpdatedAt, map[string]interface{}{"city": "x"}, "typo")
+	if !errors.Is(err, service.ErrRecordDoesNotExist) {
+		t.Errorf("CorrectVersion(2) err = %v, want ErrRecordDoesNotExist", err)
+	}
+
+	// erased versions can't be corrected, and erasures are maintenance too
+	_, err = s.EraseRecord(ctx, 1, "gdpr request")
+	if err != nil {
+		t.Fatalf("EraseRecord: %v", err)
+	}
+	_, err = s.CorrectVersion(ctx, 1, created.UpdatedAt, map[string]interface{}{"city": "x"}, "typo")
+	if !errors.Is(err, service.ErrVersionErased) {
+		t.Errorf("CorrectVersion of an erased version err = %v, want ErrVersionErased", err)
+	}
+	maintenance, err = s.GetMaintenance(ctx, 1)
+	if err != nil || len(maintenance) != 2 || maintenance[1].Operation != model.MaintenanceErasure {
+		t.Errorf("GetMaintenance = %+v, %v, want the correction and the erasure", maintenance, err)
+	}
+}
+
+func mustCreate(t *testing.T, s service.RecordService, id uint, data map[string]interface{}) model.Record {
+	t.Helper()
+	record, err := s.CreateRecord(context.Background(), id, data)
+	if err != nil {
+		t.Fatalf("CreateRecord(%d): %v", id, err)
+	}
+	return record
+}
+
+func mustUpdate(t *testing.T, s service.RecordService, prev model.Record, data map[string]interface{}) model.Record {
+	t.Helper()
+	record, err := s.UpdateRecord(context.Background(), prev, data)
+	if err != nil {
+		t.Fatalf("UpdateRecord(%d): %v", prev.ID, err)
+	}
+	return record
+}
+
+func mustParse(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func assertData(t *testing.T, record model.Record, want map[string]interface{}) {
+	t.Helper()
+	data := record.GetData()
+	for field, value := range want {
+		if data[field] != value {
+			t.Errorf("%s = %#v, want %#v", field, data[field], value)
+		}
+	}
+}
+
+func assertSameVersion(t *testing.T, got model.Record, want model.Record) {
+	t.Helper()
+	if got.ID != want.ID || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("got version %d@%v, want %d@%v", got.ID, got.UpdatedAt, want.ID, want.UpdatedAt)
+	}
+	assertData(t, got, want.GetData())
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/model"
+)
+
+// TenantRecordService keeps the records of every tenant in a
+// RecordService of its own, e.g. a sqlite file per tenant, which is
+// opened on first use.
+//
+// open fails with ErrTenantDoesNotExist for the tenants which weren't
+// provisioned. They have no records, so the methods reading records fail
+// with ErrRecordDoesNotExist, while CreateRecord fails with
+// ErrTenantDoesNotExist.
+type TenantRecordService struct {
+	open func(tenant string) (RecordService, error)
+
+	mu       sync.Mutex
+	services map[string]*tenantService
+}
+
+// tenantService is the RecordService of a tenant, once opened.
+type tenantService struct {
+	mu      sync.Mutex
+	service RecordService
+}
+
+func NewTenantRecordService(open func(tenant string) (RecordService, error)) *TenantRecordService {
+	return &TenantRecordService{open: open, services: map[string]*tenantService{}}
+}
+
+// service returns the RecordService of the tenant of ctx. Tenants are
+// opened outside of the lock of s, so that requests to the other tenants
+// don't wait for it.
+func (s *TenantRecordService) service(ctx context.Context) (RecordService, error) {
+	name := tenant.FromContext(ctx)
+
+	s.mu.Lock()
+	opened, ok := s.services[name]
+	if !ok {
+		opened = &tenantService{}
+		s.services[name] = opened
+	}
+	s.mu.Unlock()
+
+	opened.mu.Lock()
+	defer opened.mu.Unlock()
+
+	if opened.service != nil {
+		return opened.service, nil
+	}
+	service, err := s.open(name)
+	if err != nil {
+		// tenants which failed to open are forgotten, so that unknown
+		// tenants don't pile up
+		s.mu.Lock()
+		if s.services[name] == opened {
+			delete(s.services, name)
+		}
+		s.mu.Unlock()
+		if errors.Is(err, ErrTenantDoesNotExist) {
+			return nil, ErrRecordDoesNotExist
+		}
+		return nil, err
+	}
+	opened.service = service
+	return service, nil
+}
+
+func (s *TenantRecordService) GetRecord(ctx context.Context, id uint) (model.Record, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return model.Record{}, err
+	}
+	return service.GetRecord(ctx, id)
+}
+
+func (s *TenantRecordService) GetRecordAt(ctx context.Context, id uint, at time.Time) (model.Record, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return model.Record{}, err
+	}
+	return service.GetRecordAt(ctx, id, at)
+}
+
+func (s *TenantRecordService) GetVersions(ctx context.Context, id uint) ([]model.Record, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return []model.Record{}, err
+	}
+	return service.GetVersions(ctx, id)
+}
+
+func (s *TenantRecordService) CreateRecord(ctx context.Context, id uint, unsafeData map[string]interface{}) (model.Record, error) {
+	service, err := s.service(ctx)
+	if errors.Is(err, ErrRecordDoesNotExist) {
+		return model.Record{}, ErrTenantDoesNotExist
+	}
+	if err != nil {
+		return model.Record{}, err
+	}
+	return service.CreateRecord(ctx, id, unsafeData)
+}
+
+func (s *TenantRecordService) UpdateRecord(ctx context.Context, prevRecord model.Record, unsafeData map[string]interface{}) (model.Record, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return model.Record{}, err
+	}
+	return service.UpdateRecord(ctx, prevRecord, unsafeData)
+}
+
+func (s *TenantRecordService) EraseRecord(ctx context.Context, id uint, reason string) (model.Erasure, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return model.Erasure{}, err
+	}
+	return service.EraseRecord(ctx, id, reason)
+}
+
+func (s *TenantRecordService) GetErasures(ctx context.Context, id uint) ([]model.Erasure, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return []model.Erasure{}, err
+	}
+	return service.GetErasures(ctx, id)
+}
+
+func (s *TenantRecordService) CorrectVersion(ctx context.Context, id uint, at time.Time, unsafeData map[string]interface{}, reason string) (model.Maintenance, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return model.Maintenance{}, err
+	}
+	return service.CorrectVersion(ctx, id, at, unsafeData, reason)
+}
+
+func (s *TenantRecordService) GetMaintenance(ctx context.Context, id uint) ([]model.Maintenance, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return []model.Maintenance{}, err
+	}
+	return service.GetMaintenance(ctx, id)
+}
+
+func (s *TenantRecordService) VerifyRecord(ctx context.Context, id uint) (chain.Report, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return chain.Report{}, err
+	}
+	return service.VerifyRecord(ctx, id)
+}
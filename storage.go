@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rainbowmga/timetravel/apikey"
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/config"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/envelope"
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/webhook"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// storage is the database selected by the config along with its
+// migrations and RecordService. db is nil for in-memory storage.
+//
+// Every version written through records is published to changes, and
+// queued for the webhooks. webhooks and keys are nil for in-memory
+// storage, and webhooks are also nil with a sqlite file per tenant,
+// since their outbox must be in the same database as the versions.
+//
+// cipher encrypts fields of the versions when the config has a key file,
+// and chainKey keys the links of their chain.
+type storage struct {
+	db         *gorm.DB
+	clock      clock.Clock
+	migrations []migration.Migration
+	records    service.RecordService
+	changes    *service.ChangeFeed
+	webhooks   *webhook.Store
+	keys       *apikey.Store
+	cipher     *envelope.Cipher
+	chainKey   chain.Key
+}
+
+func openStorage(c config.Config) (storage, error) {
+	store, err := openDatabase(c)
+	if err != nil {
+		return storage{}, err
+	}
+
+	store.changes = service.NewChangeFeed()
+	records := service.NewPublishingRecordService(store.records, store.changes)
+	store.records = &records
+	return store, nil
+}
+
+func openDatabase(c config.Config) (storage, error) {
+	// webhook deliveries are scheduled in real time, whatever the clock
+	// of the records
+	wall := clock.Real()
+	clock := newClock(c)
+
+	var cipher *envelope.Cipher
+	if c.EncryptionKeyFile != "" {
+		encryption := envelope.NewCipher(envelope.NewKeyring(c.EncryptionKeyFile), c.EncryptedFields)
+		cipher = &encryption
+	}
+
+	var chainKey chain.Key
+	if c.ChainKeyFile != "" {
+		var err error
+		chainKey, err = chain.LoadKey(c.ChainKeyFile)
+		if err != nil {
+			return storage{}, fmt.Errorf("could not load the chain key; `timetravel chain keygen` creates it: %w", err)
+		}
+	}
+
+	switch c.Storage {
+	case config.StorageMemory:
+		records := service.NewMemoryRecordService(clock)
+		records.SetChainKey(chainKey)
+		return storage{clock: clock, records: &records, chainKey: chainKey}, nil
+	case config.StoragePostgres:
+		db, err := model.OpenPostgresDb(c.PostgresDSN)
+		if err != nil {
+			return storage{}, err
+		}
+		records := service.NewPostgresRecordService(db, clock)
+		records.SetChainKey(chainKey)
+		if cipher != nil {
+			records.SetSealer(cipher)
+		}
+		webhooks := webhook.NewStore(db, wall)
+		if cipher != nil {
+			webhooks.SetSealer(cipher)
+		}
+		records.AddVersionHook(webhooks.Enqueue)
+		keys := apikey.NewStore(db, clock)
+		return storage{db: db, clock: clock, migrations: migration.Postgres, records: &records, webhooks: &webhooks, keys: &keys, cipher: cipher, chainKey: chainKey}, nil
+	default:
+		db, err := model.OpenDb(c.SQLitePath)
+		if err != nil {
+			return storage{}, err
+		}
+		if c.SQLiteTenantDir != "" {
+			records := service.NewTenantRecordService(func(tenant string) (service.RecordService, error) {
+				db, err := openTenantDb(c.SQLiteTenantDir, tenant)
+				if err != nil {
+					return nil, err
+				}
+				records := service.NewSQLiteRecordService(db, clock)
+				records.SetChainKey(chainKey)
+				if cipher != nil {
+					records.SetSealer(cipher)
+				}
+				return &records, nil
+			})
+			keys := apikey.NewStore(db, clock)
+			return storage{db: db, clock: clock, migrations: migration.SQLite, records: records, keys: &keys, cipher: cipher, chainKey: chainKey}, nil
+		}
+		records := service.NewSQLiteRecordService(db, clock)
+		records.SetChainKey(chainKey)
+		if cipher != nil {
+			records.SetSealer(cipher)
+		}
+		webhooks := webhook.NewStore(db, wall)
+		if cipher != nil {
+			webhooks.SetSealer(cipher)
+		}
+		records.AddVersionHook(webhooks.Enqueue)
+		keys := apikey.NewStore(db, clock)
+		return storage{db: db, clock: clock, migrations: migration.SQLite, records: &records, webhooks: &webhooks, keys: &keys, cipher: cipher, chainKey: chainKey}, nil
+	}
+}
+
+// openTenantDb opens the sqlite file of the records of a tenant,
+// migrating it as needed, or fails with service.ErrTenantDoesNotExist
+// when it wasn't provisioned, see runTenant. The file of the default
+// tenant is created on first use.
+func openTenantDb(dir string, name string) (*gorm.DB, error) {
+	if name != tenant.Default {
+		_, err := os.Stat(filepath.Join(dir, name+".db"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, service.ErrTenantDoesNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return provisionTenantDb(dir, name)
+}
+
+// provisionTenantDb opens the sqlite file of the records of a tenant,
+// creating and migrating it as needed. Tenant names are safe file names.
+func provisionTenantDb(dir string, tenant string) (*gorm.DB, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	db, err := model.OpenDb(filepath.Join(dir, tenant+".db"))
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := migration.NewMigrator(db, migration.SQLite).Up()
+	for _, m := range applied {
+		log.Info().Msgf("applied %s to tenant %s", m, tenant)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// tenantDbs opens the sqlite files of every tenant in dir.
+func tenantDbs(dir string) ([]*gorm.DB, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	dbs := []*gorm.DB{}
+	for _, path := range paths {
+		db, err := openTenantDb(dir, strings.TrimSuffix(filepath.Base(path), ".db"))
+		if err != nil {
+			return nil, err
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs, nil
+}
+
+// newClock returns the server clock, frozen or offset by the config.
+func newClock(c config.Config) clock.Clock {
+	if !c.FreezeTime.IsZero() {
+		log.Warn().Msgf("server clock is frozen at %s", c.FreezeTime.Format(time.RFC3339))
+		return clock.NewFake(c.FreezeTime)
+	}
+	if c.TimeOffset != 0 {
+		log.Warn().Msgf("server clock is offset by %s", c.TimeOffset)
+		return clock.Offset(clock.Real(), c.TimeOffset)
+	}
+	return clock.Real()
+}
+
+// clockHeader describes the server clock for the X-Timetravel-Clock
+// header of the responses, or is empty when it is the real one.
+func clockHeader(c config.Config) string {
+	if !c.FreezeTime.IsZero() {
+		return "frozen=" + c.FreezeTime.Format(time.RFC3339)
+	}
+	if c.TimeOffset != 0 {
+		return "offset=" + c.TimeOffset.String()
+	}
+	return ""
+}
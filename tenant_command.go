@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/rainbowmga/timetravel/concern/config"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rs/zerolog/log"
+)
+
+const tenantUsage = "usage: timetravel --sqlite-tenant-dir <dir> tenant add <name>"
+
+// runTenant implements `timetravel tenant add`, which provisions the
+// sqlite file of a tenant, so that its records can be written.
+func runTenant(c config.Config, args []string) {
+	if len(args) != 2 || args[0] != "add" || c.SQLiteTenantDir == "" {
+		log.Fatal().Msg(tenantUsage)
+	}
+	if !tenant.IsValid(args[1]) {
+		log.Fatal().Err(tenant.ErrInvalidTenant).Msg(tenantUsage)
+	}
+
+	_, err := provisionTenantDb(c.SQLiteTenantDir, args[1])
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not provision the tenant")
+	}
+	log.Info().Msgf("provisioned tenant %s", args[1])
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/config"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const verifyUsage = "usage: timetravel verify [--checkpoint <file>] [id]\n" +
+	"       timetravel verify checkpoint <file>"
+
+// runVerify implements `timetravel verify [id]`, which verifies the hash
+// chain of every record, or of the records of id in every tenant, and
+// fails when a chain is broken. With --checkpoint, it also fails when
+// the heads of the chains in the checkpoint are gone.
+//
+// `timetravel verify checkpoint <file>` writes the checkpoint of the
+// heads of the chains of every record to file.
+func runVerify(c config.Config, store storage, args []string) {
+	if len(args) == 2 && args[0] == "checkpoint" {
+		writeCheckpoint(c, store, args[1])
+		return
+	}
+
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	checkpointFile := fs.String("checkpoint", "", "checkpoint whose heads must still be in the chains")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 1 {
+		log.Fatal().Msg(verifyUsage)
+	}
+	var id uint64
+	if fs.NArg() == 1 {
+		var err error
+		id, err = strconv.ParseUint(fs.Arg(0), 10, 32)
+		if err != nil || id == 0 {
+			log.Fatal().Msg(verifyUsage)
+		}
+	}
+
+	verified, unchained, broken := 0, 0, 0
+	for _, record := range listRecords(c, store, uint(id)) {
+		ctx := tenant.WithTenant(context.Background(), record.Tenant)
+		report, err := store.records.VerifyRecord(ctx, record.ID)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("could not verify record %s/%d", record.Tenant, record.ID)
+		}
+		verified++
+		unchained += report.Unchained
+		if !report.Valid {
+			broken++
+			log.Error().Msgf("record %s/%d: version of %s: %s", record.Tenant, record.ID,
+				report.Broken.UpdatedAt.Format(time.RFC3339), report.Broken.Reason)
+		}
+	}
+
+	if *checkpointFile != "" {
+		broken += checkHeads(store, *checkpointFile, uint(id))
+	}
+
+	log.Info().Msgf("verified %d records, with %d versions written before they were chained", verified, unchained)
+	if broken > 0 {
+		log.Fatal().Msgf("the chain of %d records is broken", broken)
+	}
+}
+
+// writeCheckpoint writes the checkpoint of the heads of the chains of
+// every record to path.
+func writeCheckpoint(c config.Config, store storage, path string) {
+	if store.chainKey == nil {
+		log.Warn().Msg("without --chain-key-file, anyone can forge the checkpoint")
+	}
+
+	heads := []chain.Head{}
+	for _, record := range listRecords(c, store, 0) {
+		ctx := tenant.WithTenant(context.Background(), record.Tenant)
+		versions, err := store.records.GetVersions(ctx, record.ID)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("could not read record %s/%d", record.Tenant, record.ID)
+		}
+		heads = append(heads, chain.HeadOf(versions))
+	}
+
+	checkpoint := chain.NewCheckpoint(store.chainKey, store.clock.Now(), heads)
+	content, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not encode the checkpoint")
+	}
+	err = os.WriteFile(path, content, 0o644)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not write the checkpoint")
+	}
+	log.Info().Msgf("wrote the heads of %d records to %s; keep it outside of the database", len(heads), path)
+}
+
+// checkHeads checks that the heads of the checkpoint at path, of the
+// records of id or every record, are still in their chains, and returns
+// how many records lost theirs.
+func checkHeads(store storage, path string, id uint) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not read the checkpoint")
+	}
+	var checkpoint chain.Checkpoint
+	if err := json.Unmarshal(content, &checkpoint); err != nil {
+		log.Fatal().Err(err).Msg("could not read the checkpoint")
+	}
+	if !checkpoint.Valid(store.chainKey) {
+		log.Fatal().Msg("the checkpoint wasn't made with the chain key, or was changed since")
+	}
+
+	broken := 0
+	for _, head := range checkpoint.Heads {
+		if id != 0 && head.RecordID != id {
+			continue
+		}
+		ctx := tenant.WithTenant(context.Background(), head.Tenant)
+		reason := "the record was deleted since the checkpoint"
+		versions, err := store.records.GetVersions(ctx, head.RecordID)
+		switch {
+		case errors.Is(err, service.ErrRecordDoesNotExist):
+		case err != nil:
+			log.Fatal().Err(err).Msgf("could not read record %s/%d", head.Tenant, head.RecordID)
+		default:
+			maintenance, err := store.records.GetMaintenance(ctx, head.RecordID)
+			if err != nil {
+				log.Fatal().Err(err).Msgf("could not read record %s/%d", head.Tenant, head.RecordID)
+			}
+			reason = head.Check(versions, maintenance, checkpoint.TakenAt)
+		}
+
+		if reason != "" {
+			broken++
+			log.Error().Msgf("record %s/%d: checkpoint of %s: %s", head.Tenant, head.RecordID,
+				checkpoint.TakenAt.Format(time.RFC3339), reason)
+		}
+	}
+	return broken
+}
+
+// listRecords lists the records of id, or every record, in every tenant.
+func listRecords(c config.Config, store storage, id uint) []model.Record {
+	dbs := []*gorm.DB{store.db}
+	if c.SQLiteTenantDir != "" {
+		var err error
+		dbs, err = tenantDbs(c.SQLiteTenantDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not open the tenant databases")
+		}
+	}
+
+	records := []model.Record{}
+	for _, db := range dbs {
+		query := db.Unscoped().Model(&model.Record{}).Distinct("tenant", "id").Order("tenant, id")
+		if id != 0 {
+			query = query.Where("id = ?", id)
+		}
+		var found []model.Record
+		if err := query.Find(&found).Error; err != nil {
+			log.Fatal().Err(err).Msg("could not list the records")
+		}
+		records = append(records, found...)
+	}
+	return records
+}
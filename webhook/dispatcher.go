@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/logging"
+	"github.com/rs/zerolog/log"
+)
+
+// Headers of the requests sent to subscribers.
+const (
+	HeaderEvent     = "X-Timetravel-Event"
+	HeaderDelivery  = "X-Timetravel-Delivery"
+	HeaderTimestamp = "X-Timetravel-Timestamp"
+	HeaderSignature = "X-Timetravel-Signature"
+)
+
+// Sign is the signature of a delivery: the hex encoded HMAC-SHA256 of
+// the timestamp header, a dot and the body, keyed by the secret of the
+// subscription.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery received by a subscriber.
+func Verify(secret string, r *http.Request, body []byte) bool {
+	want := Sign(secret, r.Header.Get(HeaderTimestamp), body)
+	return hmac.Equal([]byte(want), []byte(r.Header.Get(HeaderSignature)))
+}
+
+// Dispatcher sends the deliveries of the outbox.
+type Dispatcher struct {
+	store  *Store
+	clock  clock.Clock
+	client *http.Client
+
+	// MaxAttempts is how many times a delivery is sent before it is
+	// moved to the dead letters.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, doubled on every
+	// following one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// PollInterval is how often Run looks for due deliveries.
+	PollInterval time.Duration
+}
+
+// NewDispatcher sends the deliveries of store when they are due by
+// clock, which should tell the real time, as the clock of store.
+func NewDispatcher(store *Store, clock clock.Clock) *Dispatcher {
+	return &Dispatcher{
+		store:        store,
+		clock:        clock,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts:  8,
+		Backoff:      30 * time.Second,
+		MaxBackoff:   time.Hour,
+		PollInterval: time.Second,
+	}
+}
+
+// Run sends due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.DispatchDue(ctx)
+		logging.LogError(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue sends every pending delivery whose next attempt is due,
+// oldest first, and returns how many were sent successfully.
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	var deliveries []Delivery
+	result := d.store.db.WithContext(ctx).
+		Preload("Subscription").
+		Where("status = ? AND next_attempt_at <= ?", StatusPending, d.clock.Now().UTC()).
+		Order("id").
+		Limit(100).
+		Find(&deliveries)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	sent := 0
+	for _, delivery := range deliveries {
+		err := d.send(ctx, delivery)
+		if err == nil {
+			sent++
+		}
+		if err := d.record(ctx, delivery, err); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery Delivery) error {
+	payload, err := d.store.Payload(ctx, delivery)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(d.clock.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", delivery.Subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(delivery.Subscription.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("subscriber responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// record saves the outcome of an attempt, scheduling the next one, or
+// deletes the delivery once it is sent.
+func (d *Dispatcher) record(ctx context.Context, delivery Delivery, sendErr error) error {
+	now := d.clock.Now().UTC()
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts}
+
+	switch {
+	case sendErr == nil:
+		log.Debug().Msgf("webhook delivery %d sent", delivery.ID)
+		return d.store.db.WithContext(ctx).Delete(&Delivery{}, delivery.ID).Error
+	case attempts >= d.MaxAttempts:
+		log.Warn().Err(sendErr).Msgf("webhook delivery %d is dead after %d attempts", delivery.ID, attempts)
+		updates["status"] = StatusDead
+		updates["last_error"] = sendErr.Error()
+	default:
+		log.Debug().Err(sendErr).Msgf("webhook delivery %d failed", delivery.ID)
+		updates["next_attempt_at"] = now.Add(d.backoff(attempts))
+		updates["last_error"] = sendErr.Error()
+	}
+
+	return d.store.db.WithContext(ctx).
+		Model(&Delivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(updates).Error
+}
+
+// backoff is the wait after a number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait
+}
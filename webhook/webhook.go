@@ -0,0 +1,304 @@
+// Package webhook notifies subscribers of new record versions.
+//
+// Deliveries are written to an outbox table by Store.Enqueue, in the same
+// transaction as the version they describe, and sent by a Dispatcher in
+// the background. A delivery is sent at least once: it is retried with
+// exponential backoff until the subscriber answers with a 2xx, and moved
+// to the dead letters after too many attempts.
+//
+// The outbox only refers to the versions: the body of a delivery is built
+// from them as it is sent, so encrypted fields are never stored in clear
+// and erased versions are sent redacted. Deliveries are deleted once
+// delivered.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/concern/tenant"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"gorm.io/gorm"
+)
+
+var ErrSubscriptionDoesNotExist = errors.New("webhook with that id does not exist")
+var ErrInvalidURL = errors.New("url must be an absolute http or https url")
+var ErrInvalidField = errors.New("fields must be mutable fields of a record")
+
+const (
+	EventCreated = "record.created"
+	EventUpdated = "record.updated"
+)
+
+const (
+	StatusPending = "pending"
+	StatusDead    = "dead"
+)
+
+// Subscription is a url notified of the new versions of every record of
+// its tenant, or only of those changing one of Fields when it isn't empty.
+type Subscription struct {
+	Tenant    string           `json:"-"`
+	ID        uint             `json:"id"`
+	URL       string           `json:"url"`
+	Secret    string           `json:"-"`
+	Fields    model.StringList `gorm:"type:text" json:"fields"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+func (Subscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// matches is true when the subscription wants a version that changed
+// these fields.
+func (s Subscription) matches(changed []string) bool {
+	if len(s.Fields) == 0 {
+		return len(changed) > 0
+	}
+	for _, field := range changed {
+		for _, wanted := range s.Fields {
+			if field == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Delivery is an event waiting in the outbox, or dead. It refers to the
+// version it announces, and to the previous one for an update, by the
+// time they were updated at.
+type Delivery struct {
+	ID             uint             `json:"id"`
+	SubscriptionID uint             `json:"subscription_id"`
+	Subscription   Subscription     `json:"-"`
+	RecordID       uint             `json:"record_id"`
+	Event          string           `json:"event"`
+	VersionAt      time.Time        `json:"-"`
+	PreviousAt     *time.Time       `json:"-"`
+	ChangedFields  model.StringList `gorm:"type:text" json:"-"`
+	Status         string           `json:"status"`
+	Attempts       int              `json:"attempts"`
+	LastError      string           `json:"last_error"`
+	NextAttemptAt  time.Time        `json:"next_attempt_at"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// Payload is the json body posted to subscribers.
+type Payload struct {
+	Event         string            `json:"event"`
+	ChangedFields []string          `json:"changed_fields"`
+	Record        model.RecordJSON  `json:"record"`
+	Previous      *model.RecordJSON `json:"previous"`
+}
+
+// Store manages the subscriptions and the outbox.
+//
+// Times are stored in UTC so they compare correctly as sqlite strings.
+// Its clock schedules the deliveries, so it should tell the real time
+// even when the records are dated by a frozen or offset one.
+type Store struct {
+	db     *gorm.DB
+	clock  clock.Clock
+	sealer service.Sealer
+}
+
+func NewStore(db *gorm.DB, clock clock.Clock) Store {
+	return Store{db: db, clock: clock}
+}
+
+// SetSealer decrypts the versions sent with sealer, which should be the
+// sealer of the records.
+func (s *Store) SetSealer(sealer service.Sealer) {
+	s.sealer = sealer
+}
+
+// CreateSubscription subscribes a url, signing its deliveries with secret.
+func (s *Store) CreateSubscription(ctx context.Context, rawURL string, secret string, fields []string) (Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return Subscription{}, ErrInvalidURL
+	}
+	for _, field := range fields {
+		if !isMutableField(field) {
+			return Subscription{}, ErrInvalidField
+		}
+	}
+
+	subscription := Subscription{
+		Tenant:    tenant.FromContext(ctx),
+		URL:       rawURL,
+		Secret:    secret,
+		Fields:    append(model.StringList{}, fields...),
+		CreatedAt: s.clock.Now().UTC(),
+	}
+	result := s.db.WithContext(ctx).Create(&subscription)
+	return subscription, result.Error
+}
+
+// ListSubscriptions returns the subscriptions of the tenant of ctx.
+func (s *Store) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	subscriptions := []Subscription{}
+	result := s.subscriptions(ctx).Order("id").Find(&subscriptions)
+	return subscriptions, result.Error
+}
+
+// DeleteSubscription unsubscribes a url, dropping its deliveries.
+func (s *Store) DeleteSubscription(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("tenant = ?", tenant.FromContext(ctx)).Delete(&Subscription{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubscriptionDoesNotExist
+		}
+		return tx.Where("subscription_id = ?", id).Delete(&Delivery{}).Error
+	})
+}
+
+// ListDeadLetters returns the deliveries to the subscriptions of the
+// tenant of ctx that ran out of attempts, newest first.
+func (s *Store) ListDeadLetters(ctx context.Context) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	result := s.db.WithContext(ctx).
+		Preload("Subscription").
+		Where("subscription_id IN (?)", s.subscriptions(ctx).Select("id")).
+		Where("status = ?", StatusDead).
+		Order("id desc").
+		Find(&deliveries)
+	return deliveries, result.Error
+}
+
+// subscriptions queries the subscriptions of the tenant of ctx.
+func (s *Store) subscriptions(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Model(&Subscription{}).Where("tenant = ?", tenant.FromContext(ctx))
+}
+
+// Enqueue writes a delivery of a new version for every subscription
+// interested in it. It is a service.VersionHook, so the deliveries are
+// committed along with the version.
+func (s *Store) Enqueue(tx *gorm.DB, prev *model.Record, record model.Record) error {
+	var subscriptions []Subscription
+	result := tx.Where("tenant = ?", record.Tenant).Find(&subscriptions)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	changed := changedFields(prev, record)
+
+	event := EventCreated
+	var previousAt *time.Time
+	if prev != nil {
+		event = EventUpdated
+		previousAt = &prev.UpdatedAt
+	}
+
+	now := s.clock.Now().UTC()
+	for _, subscription := range subscriptions {
+		if !subscription.matches(changed) {
+			continue
+		}
+		delivery := Delivery{
+			SubscriptionID: subscription.ID,
+			RecordID:       record.ID,
+			Event:          event,
+			VersionAt:      record.UpdatedAt,
+			PreviousAt:     previousAt,
+			ChangedFields:  changed,
+			Status:         StatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		result := tx.Omit("Subscription").Create(&delivery)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
+
+// Payload builds the body of a delivery, whose Subscription must be
+// loaded, from the versions it refers to as they are now.
+func (s *Store) Payload(ctx context.Context, delivery Delivery) (Payload, error) {
+	db := s.db.WithContext(ctx)
+	tenant := delivery.Subscription.Tenant
+
+	record, err := s.version(db, tenant, delivery.RecordID, delivery.VersionAt)
+	if err != nil {
+		return Payload{}, err
+	}
+	payload := Payload{Event: delivery.Event, ChangedFields: append([]string{}, delivery.ChangedFields...)}
+	payload.Record, _ = record.ToJSON()
+
+	if delivery.PreviousAt != nil {
+		prev, err := s.version(db, tenant, delivery.RecordID, *delivery.PreviousAt)
+		if err != nil {
+			return Payload{}, err
+		}
+		previous, _ := prev.ToJSON()
+		payload.Previous = &previous
+	}
+	return payload, nil
+}
+
+// version reads the version of a record updated at a time, decrypted.
+func (s *Store) version(db *gorm.DB, tenant string, id uint, at time.Time) (model.Record, error) {
+	var record model.Record
+	err := db.Unscoped().
+		Where("tenant = ? AND id = ? AND updated_at = ?", tenant, id, model.Timestamp(db, at)).
+		First(&record).Error
+	if err != nil {
+		return model.Record{}, err
+	}
+	if s.sealer != nil {
+		err = s.sealer.Open(db, &record)
+	}
+	return record, err
+}
+
+// changedFields lists the mutable fields set by a new record, or changed
+// by a new version.
+func changedFields(prev *model.Record, record model.Record) []string {
+	before := model.Record{}.GetData()
+	if prev != nil {
+		before = prev.GetData()
+	}
+	after := record.GetData()
+
+	changed := []string{}
+	for _, field := range record.MutableFields() {
+		if !equal(before[field], after[field]) {
+			changed = append(changed, field)
+		}
+	}
+	return changed
+}
+
+func equal(a interface{}, b interface{}) bool {
+	aTime, aOk := a.(time.Time)
+	bTime, bOk := b.(time.Time)
+	if aOk && bOk {
+		return aTime.Equal(bTime)
+	}
+	return a == b
+}
+
+func isMutableField(field string) bool {
+	for _, mutable := range (model.Record{}).MutableFields() {
+		if field == mutable {
+			return true
+		}
+	}
+	return false
+}
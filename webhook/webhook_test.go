@@ -0,0 +1,235 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rainbowmga/timetravel/chain"
+	"github.com/rainbowmga/timetravel/concern/clock"
+	"github.com/rainbowmga/timetravel/migration"
+	"github.com/rainbowmga/timetravel/model"
+	"github.com/rainbowmga/timetravel/service"
+	"github.com/rainbowmga/timetravel/webhook"
+	"gorm.io/gorm"
+)
+
+// receiver counts the deliveries it receives, and records those it
+// accepts, failing with status unless it is 200.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	attempts int
+	payloads []webhook.Payload
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if !webhook.Verify(secret, req, body) {
+			t.Errorf("invalid signature %q", req.Header.Get(webhook.HeaderSignature))
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.attempts++
+		if r.status != http.StatusOK {
+			w.WriteHeader(r.status)
+			return
+		}
+		var payload webhook.Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error(err)
+		}
+		r.payloads = append(r.payloads, payload)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func setup(t *testing.T) (service.RecordService, *webhook.Store, *clock.Fake, *gorm.DB) {
+	db, err := model.OpenDb(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migration.NewMigrator(db, migration.SQLite).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := clock.NewFake(time.Date(2024, 8, 25, 16, 13, 2, 0, time.UTC))
+	records := service.NewSQLiteRecordService(db, clock)
+	records.SetChainKey(chain.Key("the chain key of the webhook tests"))
+	store := webhook.NewStore(db, clock)
+	records.AddVersionHook(store.Enqueue)
+	return &records, &store, clock, db
+}
+
+func TestDeliveries(t *testing.T) {
+	ctx := context.Background()
+	records, store, clock, db := setup(t)
+	dispatcher := webhook.NewDispatcher(store, clock)
+
+	billing := newReceiver(t, "billing secret")
+	everything := newReceiver(t, "other secret")
+	if _, err := store.CreateSubscription(ctx, billing.URL, "billing secret", []string{"street", "city"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateSubscription(ctx, everything.URL, "other secret", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := records.CreateRecord(ctx, 30, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	record, err = records.UpdateRecord(ctx, record, map[string]interface{}{"city": "Cupertino"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	_, err = records.UpdateRecord(ctx, record, map[string]interface{}{"first_name": "Steven"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := dispatcher.DispatchDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 4 {
+		t.Errorf("sent %d deliveries, want 4", sent)
+	}
+
+	if len(billing.payloads) != 1 {
+		t.Fatalf("billing got %d deliveries, want 1", len(billing.payloads))
+	}
+	payload := billing.payloads[0]
+	if payload.Event != webhook.EventUpdated || payload.Record.Data["city"] != "Cupertino" ||
+		payload.Previous == nil || payload.Previous.Data["city"] != "" {
+		t.Errorf("got billing payload %+v", payload)
+	}
+	if len(payload.ChangedFields) != 1 || payload.ChangedFields[0] != "city" {
+		t.Errorf("got changed fields %v, want [city]", payload.ChangedFields)
+	}
+
+	if len(everything.payloads) != 3 || everything.payloads[0].Event != webhook.EventCreated {
+		t.Errorf("got deliveries %+v, want 3 starting with a creation", everything.payloads)
+	}
+
+	var outbox int64
+	if err := db.Model(&webhook.Delivery{}).Count(&outbox).Error; err != nil {
+		t.Fatal(err)
+	}
+	if outbox != 0 {
+		t.Errorf("%d deliveries left in the outbox, want them deleted once delivered", outbox)
+	}
+}
+
+func TestDeliveriesOfErasedRecords(t *testing.T) {
+	ctx := context.Background()
+	records, store, clock, _ := setup(t)
+	dispatcher := webhook.NewDispatcher(store, clock)
+
+	billing := newReceiver(t, "secret")
+	if _, err := store.CreateSubscription(ctx, billing.URL, "secret", nil); err != nil {
+		t.Fatal(err)
+	}
+	record, err := records.CreateRecord(ctx, 30, map[string]interface{}{"first_name": "Steve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if _, err := records.UpdateRecord(ctx, record, map[string]interface{}{"city": "Cupertino"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if _, err := records.EraseRecord(ctx, 30, "asked by the customer"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dispatcher.DispatchDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(billing.payloads) != 2 {
+		t.Fatalf("got %d deliveries, want 2", len(billing.payloads))
+	}
+	for _, payload := range billing.payloads {
+		if payload.Record.Data["first_name"] != model.Redacted ||
+			(payload.Previous != nil && payload.Previous.Data["first_name"] != model.Redacted) {
+			t.Errorf("got payload %+v, want the erased versions redacted", payload)
+		}
+	}
+}
+
+func TestRetriesAndDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	records, store, clock, _ := setup(t)
+	dispatcher := webhook.NewDispatcher(store, clock)
+	dispatcher.MaxAttempts = 3
+	dispatcher.Backoff = time.Minute
+
+	down := newReceiver(t, "secret")
+	down.status = http.StatusServiceUnavailable
+	if _, err := store.CreateSubscription(ctx, down.URL, "secret", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := records.CreateRecord(ctx, 30, map[string]interface{}{"first_name": "Steve"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatch := func(wantAttempted bool) {
+		t.Helper()
+		before := down.attempts
+		if _, err := dispatcher.DispatchDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if attempted := down.attempts > before; attempted != wantAttempted {
+			t.Fatalf("at %s: attempted %v, want %v", clock.Now(), attempted, wantAttempted)
+		}
+	}
+
+	dispatch(true)
+	// retried after one minute, then two
+	clock.Advance(59 * time.Second)
+	dispatch(false)
+	clock.Advance(time.Second)
+	dispatch(true)
+	clock.Advance(time.Minute)
+	dispatch(false)
+	clock.Advance(time.Minute)
+	dispatch(true)
+
+	dead, err := store.ListDeadLetters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError != "subscriber responded with 503 Service Unavailable" {
+		t.Errorf("got dead letters %+v", dead)
+	}
+	clock.Advance(time.Hour)
+	dispatch(false)
+}
+
+func TestCreateSubscriptionValidation(t *testing.T) {
+	ctx := context.Background()
+	_, store, _, _ := setup(t)
+
+	if _, err := store.CreateSubscription(ctx, "ftp://billing", "secret", nil); err != webhook.ErrInvalidURL {
+		t.Errorf("got %v, want ErrInvalidURL", err)
+	}
+	if _, err := store.CreateSubscription(ctx, "https://billing", "secret", []string{"id"}); err != webhook.ErrInvalidField {
+		t.Errorf("got %v, want ErrInvalidField", err)
+	}
+	if err := store.DeleteSubscription(ctx, 1); err != webhook.ErrSubscriptionDoesNotExist {
+		t.Errorf("got %v, want ErrSubscriptionDoesNotExist", err)
+	}
+}